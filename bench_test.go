@@ -0,0 +1,117 @@
+package ssconv
+
+import (
+	"testing"
+)
+
+func benchPost() dbPostWithReplies {
+	replies := make([]dbReply, 10)
+	for i := range replies {
+		replies[i] = dbReply{ID: "reply", Content: "content", User: "yokel"}
+	}
+	return dbPostWithReplies{
+		dbPost:  dbPost{ID: "post", Title: "title", Content: "content", User: "yokel", Likes: 1},
+		Replies: replies,
+		Author:  dbUser{ID: "yokel", Avatar: "hello.jpg", Gender: 1, Age: 2},
+	}
+}
+
+type dbPostWithReplies struct {
+	dbPost
+	Replies []dbReply `conv:"replies"`
+	Author  dbUser    `conv:"author"`
+}
+
+type benchPostResp struct {
+	ID     string `conv:"id"`
+	Title  string `conv:"title"`
+	Likes  int    `conv:"likes"`
+	Author User   `conv:"author"`
+}
+
+type benchPostRepliesResp struct {
+	benchPostResp
+	Replies []dbReply `conv:"replies"`
+}
+
+func BenchmarkConvNestedStruct(b *testing.B) {
+	src := benchPost()
+	op := new(Options)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var dst benchPostResp
+		if err := Conv(&src, &dst, op, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkConvParallel converts from many goroutines, plans are found in the cache without lock
+func BenchmarkConvParallel(b *testing.B) {
+	src := benchPost()
+	op := new(Options).Freeze()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var dst benchPostResp
+			if err := Conv(&src, &dst, op, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkConvOptions converts with Options holding rules, unfrozen ones
+// as most callers pass them and frozen ones
+func BenchmarkConvOptions(b *testing.B) {
+	src := benchPost()
+	newOptions := func() *Options {
+		return new(Options).AddLocalRule(NewLocalRuleGroup("author").
+			AddRule("avatar", map[string]interface{}{"default": "local.jpg"}).
+			AddRule("gender", map[string]interface{}{"min": 0}))
+	}
+	for _, c := range []struct {
+		name string
+		op   *Options
+	}{
+		{"unfrozen", newOptions()},
+		{"frozen", newOptions().Freeze()},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var dst benchPostResp
+				if err := Conv(&src, &dst, c.op, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkConvDeepCopySlice(b *testing.B) {
+	src := benchPost()
+	op := new(Options).SetDeepCode(true)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var dst benchPostRepliesResp
+		if err := Conv(&src, &dst, op, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvPlainSlice(b *testing.B) {
+	src := make([]plainPoint, 100)
+	op := new(Options).SetDeepCode(true)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var dst []plainPoint
+		if err := Conv(src, &dst, op, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
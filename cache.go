@@ -0,0 +1,197 @@
+package ssconv
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCacheLimit is the number of entries each cache holds before
+// evicting one not used recently
+const DefaultCacheLimit = 4096
+
+// CacheStats reports the usage of a cache since it was last reset
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Limit     int
+}
+
+// lruCache is a map bounded by limit, evicting an entry not used recently
+// when it is full. A limit <= 0 means no bound.
+// It approximates LRU with the clock algorithm: hits are lock-free reads of a
+// sync.Map setting the reference bit of the entry, only stores take the lock.
+// Its methods mirror those of sync.Map
+type lruCache struct {
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
+	entries sync.Map // key to *lruEntry
+
+	mu    sync.Mutex // guards the fields below, taken by stores only
+	limit int
+	ring  []*lruEntry // entries in the order the clock hand visits them
+	hand  int
+}
+
+type lruEntry struct {
+	key   interface{}
+	value interface{}
+	used  uint32 // reference bit, set on hits and cleared as the hand passes
+	pos   int    // index in ring
+}
+
+func newLruCache(limit int) *lruCache {
+	return &lruCache{limit: limit}
+}
+
+var (
+	converterCache   = newLruCache(DefaultCacheLimit)
+	structFieldCache = newLruCache(DefaultCacheLimit)
+)
+
+func (c *lruCache) Load(key interface{}) (interface{}, bool) {
+	if v, ok := c.entries.Load(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		e := v.(*lruEntry)
+		if atomic.LoadUint32(&e.used) == 0 { // written only once per pass of the hand
+			atomic.StoreUint32(&e.used, 1)
+		}
+		return e.value, true
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return nil, false
+}
+
+// LoadOrStore returns the existing value of key if present,
+// otherwise it stores and returns value. loaded is true if the value was loaded
+func (c *lruCache) LoadOrStore(key interface{}, value interface{}) (actual interface{}, loaded bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.entries.Load(key); ok {
+		e := v.(*lruEntry)
+		atomic.StoreUint32(&e.used, 1)
+		return e.value, true
+	}
+	c.store(key, value)
+	return value, false
+}
+
+func (c *lruCache) Store(key interface{}, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(key, value)
+}
+
+// store adds or replaces the entry of key, entries are never changed in place
+// since they are read without the lock
+func (c *lruCache) store(key interface{}, value interface{}) {
+	e := &lruEntry{key: key, value: value}
+	if v, ok := c.entries.Load(key); ok {
+		e.pos = v.(*lruEntry).pos
+		c.ring[e.pos] = e
+		c.entries.Store(key, e)
+		return
+	}
+	c.evict(1)
+	e.pos = len(c.ring)
+	c.ring = append(c.ring, e)
+	c.entries.Store(key, e)
+}
+
+// evict makes room for room entries. It moves the hand over the ring, giving
+// entries used since its last pass a second chance and removing those which were not
+func (c *lruCache) evict(room int) {
+	for c.limit > 0 && len(c.ring) > 0 && len(c.ring)+room > c.limit {
+		if c.hand >= len(c.ring) {
+			c.hand = 0
+		}
+		e := c.ring[c.hand]
+		if atomic.LoadUint32(&e.used) == 1 {
+			atomic.StoreUint32(&e.used, 0)
+			c.hand++
+			continue
+		}
+		c.remove(e)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// remove takes e out of the map and the ring, the last entry of the ring fills its place
+func (c *lruCache) remove(e *lruEntry) {
+	c.entries.Delete(e.key)
+	last := c.ring[len(c.ring)-1]
+	c.ring[e.pos] = last
+	last.pos = e.pos
+	c.ring[len(c.ring)-1] = nil
+	c.ring = c.ring[:len(c.ring)-1]
+}
+
+func (c *lruCache) Delete(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.entries.Load(key); ok {
+		c.remove(v.(*lruEntry))
+	}
+}
+
+func (c *lruCache) setLimit(limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit = limit
+	c.evict(0)
+}
+
+func (c *lruCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.ring {
+		c.entries.Delete(e.key)
+	}
+	c.ring = nil
+	c.hand = 0
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.evictions, 0)
+}
+
+func (c *lruCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Entries:   len(c.ring),
+		Limit:     c.limit,
+	}
+}
+
+// SetCacheLimit bounds the converter cache and the struct field cache to limit
+// entries each, a limit <= 0 removes the bound.
+// Evicted plans are built again when they are needed
+func SetCacheLimit(limit int) {
+	converterCache.setLimit(limit)
+	structFieldCache.setLimit(limit)
+}
+
+// ConverterCacheStats reports the usage of the cache of converters, one entry
+// per src type, dst type and Options
+func ConverterCacheStats() CacheStats {
+	return converterCache.stats()
+}
+
+// StructFieldCacheStats reports the usage of the cache of parsed struct fields,
+// one entry per struct type and Options
+func StructFieldCacheStats() CacheStats {
+	return structFieldCache.stats()
+}
+
+// ResetCaches drops every cached plan and clears the counters, it keeps the limits.
+// Conversions running meanwhile are not affected
+func ResetCaches() {
+	converterCache.reset()
+	structFieldCache.reset()
+}
@@ -0,0 +1,57 @@
+// Package example shows the code generated by ssconvgen, its tests compare
+// the generated functions with ssconv.Conv
+package example
+
+import (
+	"errors"
+	"ssconv"
+)
+
+//go:generate go run ssconv/cmd/ssconvgen -pair DbPost:Post -out model_gen.go -check
+
+type DbModel struct {
+	ID      string `conv:"id"`
+	Created int64
+}
+
+type DbUser struct {
+	DbModel
+	Name   string `conv:"name"`
+	Avatar string `conv:"avatar"`
+	Age    int    `conv:"age"`
+}
+
+type DbPost struct {
+	DbModel
+	Title  string `conv:"title"`
+	Author DbUser `conv:"author"`
+	Tags   []string
+	Likes  int `conv:"likes"`
+}
+
+type User struct {
+	ID     string `conv:"id"`
+	Name   string `conv:"name,func,FormatName"`
+	Avatar string `conv:"avatar,ignoreEmpty"`
+	Age    int    `conv:"-"`
+}
+
+func (u *User) FormatName(src DbUser, list ssconv.ParamList) error {
+	if src.Name == "" {
+		return errors.New("name is empty")
+	}
+	u.Name = "@" + src.Name
+	return nil
+}
+
+type Post struct {
+	ID      string `conv:"id"`
+	Created int64
+	Title   string `conv:"title"`
+	Author  User   `conv:"author"`
+	Tags    []string
+	Likes   int    `conv:"likes,ignoreEmpty"`
+	Viewer  string `conv:"viewer,param,session.user"`
+	Locale  string `conv:"locale,param,locale,ignoreEmpty"`
+	Page    int64  `conv:"page,param,page,ignoreEmpty"`
+}
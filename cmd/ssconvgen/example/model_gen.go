@@ -0,0 +1,113 @@
+// Code generated by ssconvgen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"reflect"
+	"ssconv"
+)
+
+// ConvDbPostToPost converts src to dst like ssconv.Conv with nil Options
+func ConvDbPostToPost(src *DbPost, dst *Post, list ssconv.ParamList) (err error) {
+	if ssconvgenCheck {
+		want := *dst
+		defer func() {
+			if err != nil {
+				return
+			}
+			if cerr := ssconv.Conv(src, &want, nil, list); cerr != nil {
+				err = fmt.Errorf("ssconvgen check: Conv failed: %v", cerr)
+				return
+			}
+			if !reflect.DeepEqual(*dst, want) {
+				err = fmt.Errorf("ssconvgen check: generated %+v differs from Conv %+v", *dst, want)
+			}
+		}()
+	}
+	dst.ID = src.DbModel.ID
+	dst.Created = src.DbModel.Created
+	dst.Title = src.Title
+	if err := ConvDbUserToUser(&src.Author, &dst.Author, list); err != nil {
+		return fmt.Errorf("Author: %w", err)
+	}
+	dst.Tags = src.Tags
+	if src.Likes != 0 {
+		dst.Likes = src.Likes
+	}
+	if v, ok := ssconvgenParam(list, "session", "user"); ok {
+		if tv, ok := v.(string); ok {
+			dst.Viewer = tv
+		} else if err := ssconv.Conv(v, &dst.Viewer, nil, list); err != nil {
+			return fmt.Errorf("Viewer: %w", err)
+		}
+	} else {
+		return fmt.Errorf("Viewer: param session.user is required but missing in list")
+	}
+	if v, ok := ssconvgenParam(list, "locale"); ok {
+		if tv, ok := v.(string); ok {
+			dst.Locale = tv
+		} else if err := ssconv.Conv(v, &dst.Locale, nil, list); err != nil {
+			return fmt.Errorf("Locale: %w", err)
+		}
+	}
+	if v, ok := ssconvgenParam(list, "page"); ok {
+		if tv, ok := v.(int64); ok {
+			dst.Page = tv
+		} else if err := ssconv.Conv(v, &dst.Page, nil, list); err != nil {
+			return fmt.Errorf("Page: %w", err)
+		}
+	}
+	return nil
+}
+
+// ConvDbUserToUser converts src to dst like ssconv.Conv with nil Options
+func ConvDbUserToUser(src *DbUser, dst *User, list ssconv.ParamList) (err error) {
+	if ssconvgenCheck {
+		want := *dst
+		defer func() {
+			if err != nil {
+				return
+			}
+			if cerr := ssconv.Conv(src, &want, nil, list); cerr != nil {
+				err = fmt.Errorf("ssconvgen check: Conv failed: %v", cerr)
+				return
+			}
+			if !reflect.DeepEqual(*dst, want) {
+				err = fmt.Errorf("ssconvgen check: generated %+v differs from Conv %+v", *dst, want)
+			}
+		}()
+	}
+	dst.ID = src.DbModel.ID
+	{
+		r0 := dst.FormatName(*src, list)
+		if r0 != nil {
+			return fmt.Errorf("Name: %w", r0)
+		}
+	}
+	if src.Avatar != "" {
+		dst.Avatar = src.Avatar
+	}
+	return nil
+}
+
+// ssconvgenParam walks nested ParamList and map[string]interface{} values of list along path
+func ssconvgenParam(list ssconv.ParamList, path ...string) (interface{}, bool) {
+	var v interface{} = list
+	for _, key := range path {
+		var ok bool
+		switch m := v.(type) {
+		case ssconv.ParamList:
+			v, ok = m[key]
+		case map[string]interface{}:
+			v, ok = m[key]
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// ssconvgenCheck makes generated functions compare their result with ssconv.Conv
+var ssconvgenCheck = false
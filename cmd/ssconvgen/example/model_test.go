@@ -0,0 +1,52 @@
+package example
+
+import (
+	"ssconv"
+	"testing"
+)
+
+func TestConvDbPostToPost(t *testing.T) {
+	ssconvgenCheck = true
+	defer func() { ssconvgenCheck = false }()
+
+	src := DbPost{
+		DbModel: DbModel{ID: "post", Created: 1},
+		Title:   "hello",
+		Author: DbUser{
+			DbModel: DbModel{ID: "yokel"},
+			Name:    "yokel",
+			Age:     2,
+		},
+		Tags: []string{"a", "b"},
+	}
+	dst := Post{Likes: 3, Author: User{Avatar: "keep.jpg"}}
+	list := ssconv.ParamList{"session": ssconv.ParamList{"user": "viewer"}}
+	if err := ConvDbPostToPost(&src, &dst, list); err != nil {
+		t.Fatal(err)
+	}
+	if dst.ID != "post" || dst.Author.Name != "@yokel" || dst.Author.Avatar != "keep.jpg" ||
+		dst.Author.Age != 0 || dst.Likes != 3 || dst.Viewer != "viewer" || dst.Locale != "" {
+		t.Error(dst)
+	}
+
+	// params of another type are converted as Conv does
+	list["page"] = 2
+	if err := ConvDbPostToPost(&src, &dst, list); err != nil || dst.Page != 2 {
+		t.Error(err, dst)
+	}
+	list["page"] = "2"
+	if err := ConvDbPostToPost(&src, &dst, list); err == nil {
+		t.Error("error of unconvertible param is expected")
+	}
+	delete(list, "page")
+
+	src.Author.Name = ""
+	if err := ConvDbPostToPost(&src, &dst, list); err == nil {
+		t.Error("error of custom func is expected")
+	}
+
+	src.Author.Name = "yokel"
+	if err := ConvDbPostToPost(&src, &dst, nil); err == nil {
+		t.Error("error of missing param is expected")
+	}
+}
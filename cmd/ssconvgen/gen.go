@@ -0,0 +1,688 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"sort"
+	"ssconv"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ssconvPath = reflect.TypeOf(ssconv.ParamList{}).PkgPath()
+
+// genField is a field of a struct as ssconv sees it, embedded structs are flattened
+type genField struct {
+	name   string // selector from the top struct, like Base.ID
+	alias  string
+	tagged bool
+	depth  int
+	hidden bool
+	typ    ast.Expr
+
+	ignoreEmpty bool
+
+	param     bool
+	paramName string
+
+	customConv bool
+	funcName   string
+}
+
+type typePair struct {
+	src string
+	dst string
+}
+
+type generator struct {
+	pkgName string
+	types   map[string]ast.Expr                 // type declarations of the package
+	methods map[string]map[string]*ast.FuncDecl // methods by receiver type name
+	imports map[string]string                   // import paths by package name
+
+	check bool
+
+	used  map[string]bool // packages used by generated code
+	param bool            // generated code looks up params
+	done  map[typePair]bool
+	queue []typePair
+	buf   bytes.Buffer
+}
+
+// generate reads the package in dir, skipping the file named out, and
+// returns the formatted source of conversion functions for pairs
+func generate(dir string, out string, pairs []string, check bool) ([]byte, error) {
+	g := &generator{
+		types:   make(map[string]ast.Expr),
+		methods: make(map[string]map[string]*ast.FuncDecl),
+		imports: make(map[string]string),
+		check:   check,
+		used:    make(map[string]bool),
+		done:    make(map[typePair]bool),
+	}
+	if err := g.load(dir, out); err != nil {
+		return nil, err
+	}
+
+	for _, p := range pairs {
+		parts := strings.Split(p, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid pair %q, should be Src:Dst", p)
+		}
+		g.enqueue(typePair{src: parts[0], dst: parts[1]})
+	}
+	for len(g.queue) > 0 {
+		p := g.queue[0]
+		g.queue = g.queue[1:]
+		if err := g.genPair(p); err != nil {
+			return nil, fmt.Errorf("%s to %s: %v", p.src, p.dst, err)
+		}
+	}
+	if g.param {
+		g.genParamHelper()
+	}
+	if g.check {
+		fmt.Fprintf(&g.buf, "\n// ssconvgenCheck makes generated functions compare their result with ssconv.Conv\nvar ssconvgenCheck = false\n")
+	}
+
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "// Code generated by ssconvgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkgName)
+	g.used[ssconvPath] = true
+	var paths []string
+	for path := range g.used {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&head, "\t%q\n", path)
+	}
+	head.WriteString(")\n")
+	head.Write(g.buf.Bytes())
+
+	src, err := format.Source(head.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %v", err)
+	}
+	return src, nil
+}
+
+func (g *generator) load(dir string, out string) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != out
+	}, 0)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) != 1 {
+		return fmt.Errorf("expect one package in %s, found %d", dir, len(pkgs))
+	}
+	for name, pkg := range pkgs {
+		g.pkgName = name
+		for _, file := range pkg.Files {
+			for _, imp := range file.Imports {
+				path, _ := strconv.Unquote(imp.Path.Value)
+				name := path[strings.LastIndex(path, "/")+1:]
+				if imp.Name != nil {
+					name = imp.Name.Name
+				}
+				g.imports[name] = path
+			}
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok {
+							g.types[ts.Name.Name] = ts.Type
+						}
+					}
+				case *ast.FuncDecl:
+					if d.Recv == nil || len(d.Recv.List) != 1 {
+						continue
+					}
+					recv := d.Recv.List[0].Type
+					if star, ok := recv.(*ast.StarExpr); ok {
+						recv = star.X
+					}
+					if ident, ok := recv.(*ast.Ident); ok {
+						if g.methods[ident.Name] == nil {
+							g.methods[ident.Name] = make(map[string]*ast.FuncDecl)
+						}
+						g.methods[ident.Name][d.Name.Name] = d
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) enqueue(p typePair) {
+	if g.done[p] {
+		return
+	}
+	g.done[p] = true
+	g.queue = append(g.queue, p)
+}
+
+func exported(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+func funcName(p typePair) string {
+	name := "Conv" + exported(p.src) + "To" + exported(p.dst)
+	if !ast.IsExported(p.src) || !ast.IsExported(p.dst) {
+		name = "conv" + name[len("Conv"):]
+	}
+	return name
+}
+
+func (g *generator) structType(name string) (*ast.StructType, bool) {
+	st, ok := g.types[name].(*ast.StructType)
+	return st, ok
+}
+
+// fields extracts fields of struct name like ssconv does: embedded structs are
+// flattened level by level and conflicts are resolved by the rules of encoding/json
+func (g *generator) fields(name string) ([]genField, error) {
+	type level struct {
+		typ    string
+		prefix string
+		depth  int
+	}
+	if _, ok := g.structType(name); !ok {
+		return nil, fmt.Errorf("%s is not a struct type of package %s", name, g.pkgName)
+	}
+
+	var fields []genField
+	visited := make(map[string]bool)
+	// types embedded more than once at a level are walked once, their fields are
+	// recorded twice to be seen as ambiguous
+	count := make(map[string]int)
+	nextCount := map[string]int{name: 1}
+	nxt := []level{{typ: name}}
+	for len(nxt) > 0 {
+		current := nxt
+		nxt = nil
+		count, nextCount = nextCount, make(map[string]int)
+		for _, now := range current {
+			if visited[now.typ] {
+				continue
+			}
+			visited[now.typ] = true
+			st, _ := g.structType(now.typ)
+			for _, f := range st.Fields.List {
+				tag := ""
+				if f.Tag != nil {
+					raw, _ := strconv.Unquote(f.Tag.Value)
+					tag = reflect.StructTag(raw).Get("conv")
+				}
+
+				if len(f.Names) == 0 { // embedded
+					ident, ok := f.Type.(*ast.Ident)
+					if !ok {
+						return nil, fmt.Errorf("embedded field %s is not supported", types.ExprString(f.Type))
+					}
+					if _, ok := g.structType(ident.Name); !ok {
+						return nil, fmt.Errorf("embedded field %s is not supported", ident.Name)
+					}
+					nextCount[ident.Name]++
+					if nextCount[ident.Name] == 1 {
+						nxt = append(nxt, level{typ: ident.Name, prefix: now.prefix + ident.Name + ".", depth: now.depth + 1})
+					}
+					continue
+				}
+
+				for _, n := range f.Names {
+					if !n.IsExported() {
+						continue
+					}
+					gf, err := g.parseField(n.Name, tag)
+					if err != nil {
+						return nil, fmt.Errorf("field %s: %v", n.Name, err)
+					}
+					gf.name = now.prefix + n.Name
+					gf.depth = now.depth
+					gf.typ = f.Type
+					fields = append(fields, gf)
+					if count[now.typ] > 1 && !gf.hidden {
+						fields = append(fields, gf)
+					}
+				}
+			}
+		}
+	}
+	return dominant(fields), nil
+}
+
+// parseField interprets a conv tag, tag options that the generator does not
+// support are errors
+func (g *generator) parseField(name string, tag string) (genField, error) {
+	var f genField
+	parts := strings.Split(tag, ",")
+	alias, opts := parts[0], parts[1:]
+	f.tagged = alias != "" && alias != "-"
+	switch alias {
+	case "":
+		f.alias = name
+	case "-":
+		f.hidden = true
+	default:
+		f.alias = alias
+	}
+
+	flags := opts
+	if len(opts) > 0 {
+		switch opts[0] {
+		case "param":
+			f.param = true
+			f.paramName = f.alias
+			flags = opts[1:]
+			if len(opts) >= 2 {
+				f.paramName = opts[1]
+				flags = opts[2:]
+			}
+		case "func":
+			f.customConv = true
+			f.funcName = f.alias
+			flags = opts[1:]
+			if len(opts) >= 2 {
+				f.funcName = opts[1]
+				flags = opts[2:]
+			}
+		}
+	}
+	for _, opt := range flags {
+		switch opt {
+		case "ignoreEmpty":
+			f.ignoreEmpty = true
+		default:
+			return f, fmt.Errorf("tag option %q is not supported by ssconvgen", opt)
+		}
+	}
+	return f, nil
+}
+
+// dominant drops fields that lose to another field of the same alias
+func dominant(fields []genField) []genField {
+	byAlias := make(map[string][]int)
+	for i, f := range fields {
+		if !f.hidden {
+			byAlias[f.alias] = append(byAlias[f.alias], i)
+		}
+	}
+	drop := make(map[int]bool)
+	for _, group := range byAlias {
+		if len(group) == 1 {
+			continue
+		}
+		depth := fields[group[0]].depth
+		for _, i := range group {
+			if fields[i].depth < depth {
+				depth = fields[i].depth
+			}
+		}
+		var shallowest, tagged []int
+		for _, i := range group {
+			if fields[i].depth == depth {
+				shallowest = append(shallowest, i)
+				if fields[i].tagged {
+					tagged = append(tagged, i)
+				}
+			}
+		}
+		dominant := -1
+		if len(shallowest) == 1 {
+			dominant = shallowest[0]
+		} else if len(tagged) == 1 {
+			dominant = tagged[0]
+		}
+		for _, i := range group {
+			if i != dominant {
+				drop[i] = true
+			}
+		}
+	}
+	var ret []genField
+	for i, f := range fields {
+		if !drop[i] && !f.hidden {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+func (g *generator) genPair(p typePair) error {
+	srcFields, err := g.fields(p.src)
+	if err != nil {
+		return err
+	}
+	dstFields, err := g.fields(p.dst)
+	if err != nil {
+		return err
+	}
+	if err := g.checkMethods(p, dstFields); err != nil {
+		return err
+	}
+	srcIndex := make(map[string]genField, len(srcFields))
+	for _, f := range srcFields {
+		srcIndex[f.alias] = f
+	}
+
+	var body bytes.Buffer
+	for _, df := range dstFields {
+		var err error
+		switch {
+		case df.customConv:
+			err = g.genFunc(&body, p, df)
+		case df.param:
+			err = g.genParam(&body, df)
+		default:
+			sf, ok := srcIndex[df.alias]
+			if !ok {
+				return fmt.Errorf("field %s not exists", df.alias)
+			}
+			err = g.genAssign(&body, sf, df)
+		}
+		if err != nil {
+			return fmt.Errorf("field %s: %v", df.name, err)
+		}
+	}
+
+	name := funcName(p)
+	fmt.Fprintf(&g.buf, "\n// %s converts src to dst like ssconv.Conv with nil Options\n", name)
+	fmt.Fprintf(&g.buf, "func %s(src *%s, dst *%s, list ssconv.ParamList) (err error) {\n", name, p.src, p.dst)
+	if g.check {
+		g.used["fmt"] = true
+		g.used["reflect"] = true
+		fmt.Fprintf(&g.buf, "if ssconvgenCheck {\nwant := *dst\ndefer func() {\nif err != nil {\nreturn\n}\n")
+		fmt.Fprintf(&g.buf, "if cerr := ssconv.Conv(src, &want, nil, list); cerr != nil {\nerr = fmt.Errorf(\"ssconvgen check: Conv failed: %%v\", cerr)\nreturn\n}\n")
+		fmt.Fprintf(&g.buf, "if !reflect.DeepEqual(*dst, want) {\nerr = fmt.Errorf(\"ssconvgen check: generated %%+v differs from Conv %%+v\", *dst, want)\n}\n}()\n}\n")
+	}
+	g.buf.Write(body.Bytes())
+	g.buf.WriteString("return nil\n}\n")
+	return nil
+}
+
+// checkMethods rejects hooks and automatic setters, which Conv calls but the
+// generated code would silently skip
+func (g *generator) checkMethods(p typePair, dstFields []genField) error {
+	for _, h := range []struct {
+		typ  string
+		name string
+	}{
+		{p.dst, "BeforeConv"},
+		{p.dst, "AfterConv"},
+		{p.src, "BeforeConvTo"},
+		{p.src, "AfterConvTo"},
+	} {
+		if _, ok := g.methodSet(h.typ)[h.name]; ok {
+			return fmt.Errorf("hook %s of %s is not supported by ssconvgen", h.name, h.typ)
+		}
+	}
+
+	taken := make(map[string]bool, 2*len(dstFields))
+	for _, f := range dstFields {
+		taken[f.alias] = true
+		taken[f.name[strings.LastIndex(f.name, ".")+1:]] = true
+	}
+	methods := g.methodSet(p.dst)
+	var names []string
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(name, "Set") && len(name) > len("Set") && !taken[name[len("Set"):]] && isSetter(methods[name]) {
+			return fmt.Errorf("setter %s of %s is not supported by ssconvgen", name, p.dst)
+		}
+	}
+	return nil
+}
+
+// methodSet returns the methods of typ including those promoted from embedded
+// structs, the shallowest method wins
+func (g *generator) methodSet(typ string) map[string]*ast.FuncDecl {
+	ret := make(map[string]*ast.FuncDecl)
+	visited := make(map[string]bool)
+	nxt := []string{typ}
+	for len(nxt) > 0 {
+		current := nxt
+		nxt = nil
+		found := make(map[string]*ast.FuncDecl)
+		for _, t := range current {
+			if visited[t] {
+				continue
+			}
+			visited[t] = true
+			for name, decl := range g.methods[t] {
+				found[name] = decl
+			}
+			st, ok := g.structType(t)
+			if !ok {
+				continue
+			}
+			for _, f := range st.Fields.List {
+				if ident, ok := f.Type.(*ast.Ident); ok && len(f.Names) == 0 {
+					nxt = append(nxt, ident.Name)
+				}
+			}
+		}
+		for name, decl := range found {
+			if _, ok := ret[name]; !ok {
+				ret[name] = decl
+			}
+		}
+	}
+	return ret
+}
+
+// isSetter reports whether decl takes one argument and returns an optional error
+func isSetter(decl *ast.FuncDecl) bool {
+	params := 0
+	for _, f := range decl.Type.Params.List {
+		params += len(f.Names)
+		if len(f.Names) == 0 {
+			params++
+		}
+	}
+	if params != 1 {
+		return false
+	}
+	results := decl.Type.Results
+	if results == nil || len(results.List) == 0 {
+		return true
+	}
+	ident, ok := results.List[0].Type.(*ast.Ident)
+	return len(results.List) == 1 && len(results.List[0].Names) <= 1 && ok && ident.Name == "error"
+}
+
+func (g *generator) genFunc(w *bytes.Buffer, p typePair, df genField) error {
+	decl, ok := g.methods[p.dst][df.funcName]
+	if !ok {
+		decl, ok = g.methods[p.dst][df.alias]
+	}
+	if !ok {
+		return fmt.Errorf("cant find method %s", df.funcName)
+	}
+	g.used["fmt"] = true
+
+	var results []string
+	errIndex := map[int]bool{}
+	valueIndex := -1
+	if decl.Type.Results != nil {
+		for _, r := range decl.Type.Results.List {
+			n := len(r.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				if ident, ok := r.Type.(*ast.Ident); ok && ident.Name == "error" {
+					errIndex[len(results)] = true
+				} else if valueIndex == -1 {
+					valueIndex = len(results)
+				}
+				results = append(results, fmt.Sprintf("r%d", len(results)))
+			}
+		}
+	}
+	for i := range results {
+		if !errIndex[i] && i != valueIndex {
+			results[i] = "_"
+		}
+	}
+
+	call := fmt.Sprintf("dst.%s(*src, list)", decl.Name.Name)
+	w.WriteString("{\n")
+	if len(results) > 0 {
+		fmt.Fprintf(w, "%s := %s\n", strings.Join(results, ", "), call)
+	} else {
+		fmt.Fprintf(w, "%s\n", call)
+	}
+	for i := range results {
+		if errIndex[i] {
+			fmt.Fprintf(w, "if r%d != nil {\nreturn fmt.Errorf(\"%s: %%w\", r%d)\n}\n", i, df.name, i)
+		}
+	}
+	if valueIndex != -1 {
+		fmt.Fprintf(w, "*dst = r%d\n", valueIndex)
+	}
+	w.WriteString("}\n")
+	return nil
+}
+
+func (g *generator) genParam(w *bytes.Buffer, df genField) error {
+	g.param = true
+	g.used["fmt"] = true
+	typ := g.typeString(df.typ)
+	var path []string
+	for _, key := range strings.Split(df.paramName, ".") {
+		path = append(path, strconv.Quote(key))
+	}
+	fmt.Fprintf(w, "if v, ok := ssconvgenParam(list, %s); ok {\n", strings.Join(path, ", "))
+	// values of other types are converted by Conv as it converts params
+	fmt.Fprintf(w, "if tv, ok := v.(%s); ok {\ndst.%s = tv\n}", typ, df.name)
+	fmt.Fprintf(w, " else if err := ssconv.Conv(v, &dst.%s, nil, list); err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n}", df.name, df.name)
+	if !df.ignoreEmpty {
+		fmt.Fprintf(w, " else {\nreturn fmt.Errorf(\"%s: param %s is required but missing in list\")\n}", df.name, df.paramName)
+	}
+	w.WriteString("\n")
+	return nil
+}
+
+func (g *generator) genAssign(w *bytes.Buffer, sf genField, df genField) error {
+	var stmt string
+	srcTyp, dstTyp := types.ExprString(sf.typ), types.ExprString(df.typ)
+	_, srcStruct := g.structType(srcTyp)
+	_, dstStruct := g.structType(dstTyp)
+	switch {
+	case srcStruct && dstStruct:
+		// structs of the package are converted field by field even if they are the same type,
+		// as Conv does
+		p := typePair{src: srcTyp, dst: dstTyp}
+		g.enqueue(p)
+		g.used["fmt"] = true
+		stmt = fmt.Sprintf("if err := %s(&src.%s, &dst.%s, list); err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n",
+			funcName(p), sf.name, df.name, df.name)
+	case srcTyp == dstTyp:
+		stmt = fmt.Sprintf("dst.%s = src.%s\n", df.name, sf.name)
+	default:
+		return fmt.Errorf("cant assign %s in src to %s in dst", srcTyp, dstTyp)
+	}
+
+	if df.ignoreEmpty {
+		fmt.Fprintf(w, "if %s {\n%s}\n", g.nonZero("src."+sf.name, sf.typ), stmt)
+		return nil
+	}
+	w.WriteString(stmt)
+	return nil
+}
+
+// nonZero returns an expression reporting whether expr of type typ is not zero
+func (g *generator) nonZero(expr string, typ ast.Expr) string {
+	switch t := g.underlying(typ).(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return expr + ` != ""`
+		case "bool":
+			return expr
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"float32", "float64", "complex64", "complex128", "byte", "rune":
+			return expr + " != 0"
+		}
+	case *ast.StarExpr, *ast.MapType, *ast.FuncType, *ast.ChanType, *ast.InterfaceType:
+		return expr + " != nil"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return expr + " != nil"
+		}
+	}
+	g.used["reflect"] = true
+	return fmt.Sprintf("!reflect.ValueOf(%s).IsZero()", expr)
+}
+
+// underlying resolves named types declared in the package
+func (g *generator) underlying(typ ast.Expr) ast.Expr {
+	for i := 0; i < 10; i++ {
+		ident, ok := typ.(*ast.Ident)
+		if !ok {
+			return typ
+		}
+		decl, ok := g.types[ident.Name]
+		if !ok {
+			return typ
+		}
+		typ = decl
+	}
+	return typ
+}
+
+// typeString returns the source of typ and records the packages it uses
+func (g *generator) typeString(typ ast.Expr) string {
+	ast.Inspect(typ, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if path, ok := g.imports[x.Name]; ok {
+					g.used[path] = true
+				}
+			}
+			return false
+		}
+		return true
+	})
+	return types.ExprString(typ)
+}
+
+func (g *generator) genParamHelper() {
+	g.buf.WriteString(`
+// ssconvgenParam walks nested ParamList and map[string]interface{} values of list along path
+func ssconvgenParam(list ssconv.ParamList, path ...string) (interface{}, bool) {
+	var v interface{} = list
+	for _, key := range path {
+		var ok bool
+		switch m := v.(type) {
+		case ssconv.ParamList:
+			v, ok = m[key]
+		case map[string]interface{}:
+			v, ok = m[key]
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+`)
+}
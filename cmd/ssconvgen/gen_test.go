@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateExample(t *testing.T) {
+	src, err := generate("example", "model_gen.go", []string{"DbPost:Post"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ioutil.ReadFile(filepath.Join("example", "model_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, expect) {
+		t.Error("example/model_gen.go is out of date, run go generate ./cmd/ssconvgen/example")
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	cases := []struct {
+		src    string
+		pair   string
+		expect string
+	}{
+		{
+			"type A struct{ V int }\ntype B struct{ V int `conv:\",default=1\"` }\n",
+			"A:B",
+			`tag option "default=1" is not supported by ssconvgen`,
+		},
+		{
+			"type A struct{ V int }\ntype B struct{ W int }\n",
+			"A:B",
+			"field W not exists",
+		},
+		{
+			"type A struct{ V int }\ntype B struct{ V string }\n",
+			"A:B",
+			"cant assign int in src to string in dst",
+		},
+		{
+			"type I struct{ V int }\ntype L struct{ I }\ntype R struct{ I }\ntype A struct {\n\tL\n\tR\n}\ntype B struct{ V int }\n",
+			"A:B",
+			"field V not exists",
+		},
+		{
+			"type A struct{ V int }\ntype B struct{ V int }\nfunc (b *B) AfterConv(src interface{}, list ssconv.ParamList) error { return nil }\n",
+			"A:B",
+			"hook AfterConv of B is not supported by ssconvgen",
+		},
+		{
+			"type A struct{ V int }\ntype E struct{}\nfunc (e E) BeforeConvTo(dst interface{}, list ssconv.ParamList) error { return nil }\ntype B struct{ V int }\ntype S struct {\n\tA\n\tE\n}\n",
+			"S:B",
+			"hook BeforeConvTo of S is not supported by ssconvgen",
+		},
+		{
+			"type A struct{ V, Count int }\ntype B struct{ V int }\nfunc (b *B) SetCount(n int) {}\n",
+			"A:B",
+			"setter SetCount of B is not supported by ssconvgen",
+		},
+		{
+			"type A struct{ V int }\n",
+			"A:C",
+			"C is not a struct type of package model",
+		},
+	}
+	for _, cs := range cases {
+		dir, err := ioutil.TempDir("", "ssconvgen")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		err = ioutil.WriteFile(filepath.Join(dir, "model.go"), []byte("package model\n\n"+cs.src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = generate(dir, "model_gen.go", []string{cs.pair}, false)
+		if err == nil || !strings.Contains(err.Error(), cs.expect) {
+			t.Error(err)
+		}
+	}
+}
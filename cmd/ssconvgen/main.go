@@ -0,0 +1,68 @@
+// Command ssconvgen generates reflection-free conversion functions for pairs of
+// struct types of a package.
+//
+// It reads the conv tags of the types the same way as ssconv.Conv with nil Options:
+// alias, "-", ignoreEmpty, param and func are supported, other tag options make
+// generation fail instead of silently diverging from Conv. So do hooks and SetX
+// setter methods, and param values of another type than their field are
+// converted by calling ssconv.Conv.
+//
+// Usage:
+//
+//	ssconvgen -dir ./model -pair dbUser:User -pair dbPost:Post -out conv_gen.go [-check]
+//
+// For every pair a function like
+//
+//	func ConvDbUserToUser(src *dbUser, dst *User, list ssconv.ParamList) error
+//
+// is generated, nested struct pairs get their own function. With -check the
+// generated functions also run ssconv.Conv when the package variable
+// ssconvgenCheck is true and fail if the results differ, tests of the package
+// set the variable to verify the generated code.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type pairFlags []string
+
+func (p *pairFlags) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pairFlags) Set(s string) error {
+	*p = append(*p, strings.Split(s, ",")...)
+	return nil
+}
+
+func main() {
+	var pairs pairFlags
+	dir := flag.String("dir", ".", "directory of the package to read")
+	out := flag.String("out", "ssconv_gen.go", "output file, relative to dir")
+	check := flag.Bool("check", false, "generate runtime comparison against ssconv.Conv")
+	flag.Var(&pairs, "pair", "type pair to convert as Src:Dst, can be repeated or comma separated")
+	flag.Parse()
+
+	if len(pairs) == 0 {
+		fmt.Fprintln(os.Stderr, "ssconvgen: no -pair given")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	outPath := filepath.Join(*dir, *out)
+	src, err := generate(*dir, filepath.Base(outPath), pairs, *check)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ssconvgen:", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(outPath, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "ssconvgen:", err)
+		os.Exit(1)
+	}
+}
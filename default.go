@@ -0,0 +1,84 @@
+package ssconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mohae/deepcopy"
+	"reflect"
+	"strconv"
+)
+
+// parseDefault parses the literal of a default tag option into a value of type tp.
+// Basic kinds are parsed with strconv, other kinds are decoded as json, whose
+// commas inside brackets and braces are kept by the tag split
+func parseDefault(tp reflect.Type, s string) reflect.Value {
+	v := reflect.New(tp).Elem()
+	var err error
+	switch tp.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, tp.Bits())
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		u, err = strconv.ParseUint(s, 10, tp.Bits())
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, tp.Bits())
+		v.SetFloat(f)
+	case reflect.Ptr:
+		elem := parseDefault(tp.Elem(), s)
+		v.Set(reflect.New(tp.Elem()))
+		v.Elem().Set(elem)
+	default:
+		err = json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	if err != nil {
+		convPanic(&ErrInvalidDefault{value: s, tp: tp, err: err})
+	}
+	return v
+}
+
+// defaultValueOf converts the value of a "default" LocalRule operation to tp,
+// strings are parsed the same way as the tag option and other values are
+// converted as Conv converts them, without loss
+func defaultValueOf(tp reflect.Type, value interface{}) reflect.Value {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(tp):
+		ret := reflect.New(tp).Elem()
+		ret.Set(v)
+		return ret
+	case v.Kind() == reflect.String:
+		return parseDefault(tp, v.String())
+	case convertible(v.Type(), tp):
+		if isNumber(v.Type()) && !fitNumber(v, tp) {
+			convPanic(&ErrInvalidDefault{value: fmt.Sprint(value), tp: tp, err: &ErrOverflow{value: v, tp: tp}})
+		}
+		return v.Convert(tp)
+	}
+	convPanic(&ErrInvalidDefault{value: fmt.Sprint(value), tp: tp, err: &ErrUnableAssignType{v.Type(), tp}})
+	return reflect.Value{}
+}
+
+// copyDefault returns a copy of default value so that dst never shares
+// memory with the cached field plan
+func copyDefault(v reflect.Value) reflect.Value {
+	if v.IsZero() {
+		return reflect.Zero(v.Type())
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Struct, reflect.Interface:
+		ret := reflect.New(v.Type()).Elem()
+		ret.Set(reflect.ValueOf(deepcopy.Copy(v.Interface())))
+		return ret
+	}
+	return v
+}
@@ -0,0 +1,61 @@
+package ssconv
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// embeddedStructType returns the struct type flattened by an anonymous field
+// of type tp, which is a struct or a pointer to struct. It returns nil for other types
+func embeddedStructType(tp reflect.Type) reflect.Type {
+	if tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp.Kind() != reflect.Struct {
+		return nil
+	}
+	return tp
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but returns false instead of
+// panicking when an embedded pointer on the way is nil
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, j := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(j)
+	}
+	return v, true
+}
+
+// allocFieldByIndex is like reflect.Value.FieldByIndex but allocates nil embedded pointers on the way
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, j := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					convPanicStr(fmt.Sprintf("cant allocate unexported embedded pointer %s", typeName(v.Type())))
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(j)
+	}
+	return v
+}
+
+// typeByIndex is like reflect.Type.FieldByIndex but returns the type of the field
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, j := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(j).Type
+	}
+	return t
+}
@@ -43,6 +43,9 @@ func typeName(tp reflect.Type) string {
 		tp = tp.Elem()
 		res += "*"
 	}
+	if tp.Name() == "" { // unnamed types like func() string
+		return res + tp.String()
+	}
 	res += tp.Name()
 	return res
 }
@@ -50,3 +53,73 @@ func typeName(tp reflect.Type) string {
 func (e *ErrUnableAssignType) Error() string {
 	return fmt.Sprintf("cant not assign %s in src to %s in dst", typeName(e.srcType), typeName(e.dstType))
 }
+
+type ErrMissingParam struct {
+	name string
+}
+
+func (e *ErrMissingParam) Error() string {
+	return fmt.Sprintf("param %s is required but missing in list", e.name)
+}
+
+type ErrInvalidDefault struct {
+	value string
+	tp    reflect.Type
+	err   error
+}
+
+func (e *ErrInvalidDefault) Error() string {
+	return fmt.Sprintf("cant use %q as default value of %s: %s", e.value, typeName(e.tp), e.err)
+}
+
+type ErrValidation struct {
+	rule   string
+	reason string
+}
+
+func (e *ErrValidation) Error() string {
+	return fmt.Sprintf("validation %s failed: %s", e.rule, e.reason)
+}
+
+type ErrHook struct {
+	hook string
+	tp   reflect.Type
+	err  error
+}
+
+func (e *ErrHook) Error() string {
+	return fmt.Sprintf("%s of %s: %s", e.hook, typeName(e.tp), e.err)
+}
+
+type ErrNilSrcPath struct {
+	path string
+}
+
+func (e *ErrNilSrcPath) Error() string {
+	return fmt.Sprintf("value on the way of %s in src is nil", e.path)
+}
+
+type ErrNilEmbedded struct {
+	field string
+}
+
+func (e *ErrNilEmbedded) Error() string {
+	return fmt.Sprintf("embedded pointer on the way of %s in src is nil", e.field)
+}
+
+type ErrOverflow struct {
+	value reflect.Value
+	tp    reflect.Type
+}
+
+func (e *ErrOverflow) Error() string {
+	return fmt.Sprintf("value %v of %s in src does not fit %s in dst", e.value, typeName(e.value.Type()), typeName(e.tp))
+}
+
+type ErrReference struct {
+	tp reflect.Type
+}
+
+func (e *ErrReference) Error() string {
+	return fmt.Sprintf("reference of %s is not allowed", e.tp)
+}
@@ -0,0 +1,270 @@
+package ssconv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PlanNode describes how a value of SrcType is converted to DstType
+type PlanNode struct {
+	SrcType   string       `json:"srcType"`
+	DstType   string       `json:"dstType"`
+	Kind      string       `json:"kind"`      // kind of dst type
+	Converter string       `json:"converter"` // one of the Converter* names
+	DeepCopy  bool         `json:"deepCopy"`
+	Recursive bool         `json:"recursive,omitempty"` // the same node is being explained above, it is not expanded again
+	Hooks     []string     `json:"hooks,omitempty"`     // hooks called around a struct
+	SrcFields []string     `json:"srcFields,omitempty"` // src fields of a struct, used or not
+	Fields    []*FieldPlan `json:"fields,omitempty"`    // dst fields of a struct
+	Elem      *PlanNode    `json:"elem,omitempty"`      // elements of a pointer, slice or map, or the value wrapped or unwrapped
+}
+
+// FieldPlan describes where a dst field takes its value from
+type FieldPlan struct {
+	Name        string    `json:"name"`
+	Alias       string    `json:"alias,omitempty"`
+	Source      string    `json:"source"`             // one of the Source* names
+	SrcField    string    `json:"srcField,omitempty"` // src field, getter or path
+	Param       string    `json:"param,omitempty"`
+	Func        string    `json:"func,omitempty"`
+	IgnoreEmpty bool      `json:"ignoreEmpty,omitempty"`
+	Default     string    `json:"default,omitempty"`
+	Setter      string    `json:"setter,omitempty"`
+	Validated   bool      `json:"validated,omitempty"`
+	Plan        *PlanNode `json:"plan,omitempty"` // nil if the value is set by func or its type is only known at conversion
+}
+
+// names of converters in PlanNode.Converter
+const (
+	ConverterBasic       = "basic"      // value is assigned
+	ConverterAssign      = "assign"     // pointer, slice or map is shared with src
+	ConverterPlain       = "plain"      // struct without reference is copied as a whole
+	ConverterPlainSlice  = "plainSlice" // slice of values without reference is copied as a whole
+	ConverterPointer     = "pointer"
+	ConverterUnwrap      = "unwrap" // src pointer is dereferenced
+	ConverterWrap        = "wrap"   // dst pointer is allocated
+	ConverterSlice       = "slice"
+	ConverterMap         = "map"
+	ConverterStruct      = "struct"
+	ConverterInline      = "inline"    // dst struct filled from flat fields of src
+	ConverterReference   = "reference" // func, chan or unsafe.Pointer is copied by reference
+	ConverterSkip        = "skip"      // reference is skipped by RefPolicy
+	ConverterUnsupported = "unsupported"
+)
+
+// sources of dst fields in FieldPlan.Source
+const (
+	SourceField   = "field"
+	SourceGetter  = "getter"
+	SourcePath    = "path"
+	SourceParam   = "param"
+	SourceFunc    = "func"
+	SourceInline  = "inline"
+	SourceDefault = "default" // missing in src, always the default value
+	SourceSetter  = "setter"  // auto setter without source, never called
+)
+
+// Explain returns the plan Conv(src, dst, options, list) follows, for any kind of types.
+// Errors of planning are returned as Conv returns them
+func Explain(src interface{}, dst interface{}, options *Options) (plan *PlanNode, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if convErr, ok := r.(ConvErr); ok {
+				err = errors.New("ssconvError: " + convErr.Path + convErr.Err.Error())
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	srcType := reflect.TypeOf(src)
+	dstType := reflect.TypeOf(dst)
+	if srcType == nil || dstType == nil {
+		convPanicStr("src and dst should not be nil")
+	}
+	if srcType.Kind() == reflect.Ptr {
+		srcType = srcType.Elem()
+	}
+	if dstType.Kind() == reflect.Ptr {
+		dstType = dstType.Elem()
+	}
+	e := explainer{visiting: make(map[explainKey]bool)}
+	return e.explain(srcType, dstType, options.Freeze()), nil
+}
+
+type explainKey struct {
+	srcType reflect.Type
+	dstType reflect.Type
+	options string
+}
+
+// explainer walks plans the same way newConv builds them
+type explainer struct {
+	visiting map[explainKey]bool
+}
+
+func (e *explainer) explain(srcType reflect.Type, dstType reflect.Type, options *Options) *PlanNode {
+	// make sure the plan can be built, errors carry the same path as in Conv
+	cacheConverter(srcType, dstType, options)
+
+	node := &PlanNode{
+		SrcType:  srcType.String(),
+		DstType:  dstType.String(),
+		Kind:     dstType.Kind().String(),
+		DeepCopy: options.DeepCopy,
+	}
+	key := explainKey{srcType: srcType, dstType: dstType, options: options.key()}
+	if e.visiting[key] {
+		node.Recursive = true
+		return node
+	}
+	e.visiting[key] = true
+	defer delete(e.visiting, key)
+
+	if srcType == dstType && newPlainConverter(dstType, options) != nil {
+		node.Converter = ConverterPlain
+		if dstType.Kind() == reflect.Slice {
+			node.Converter = ConverterPlainSlice
+		}
+		return node
+	}
+
+	if srcType != dstType && srcType.Kind() != reflect.Interface && dstType.Kind() != reflect.Interface {
+		switch srcDepth, dstDepth := ptrDepth(srcType), ptrDepth(dstType); {
+		case srcDepth > dstDepth:
+			node.Converter = ConverterUnwrap
+			node.Elem = e.explain(srcType.Elem(), dstType, options)
+			return node
+		case srcDepth < dstDepth:
+			node.Converter = ConverterWrap
+			node.Elem = e.explain(srcType, dstType.Elem(), options)
+			return node
+		}
+	}
+
+	switch dstType.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128,
+		reflect.String, reflect.Array:
+		node.Converter = ConverterBasic
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if !options.DeepCopy && srcType.AssignableTo(dstType) {
+			node.Converter = ConverterAssign
+			break
+		}
+		node.Converter = map[reflect.Kind]string{
+			reflect.Ptr:   ConverterPointer,
+			reflect.Slice: ConverterSlice,
+			reflect.Map:   ConverterMap,
+		}[dstType.Kind()]
+		node.Elem = e.explain(srcType.Elem(), dstType.Elem(), options)
+	case reflect.Struct:
+		node.Converter = ConverterStruct
+		e.explainStruct(node, srcType, dstType, options, "")
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		node.Converter = ConverterReference
+		if options.RefPolicy == RefSkip {
+			node.Converter = ConverterSkip
+		}
+	default:
+		node.Converter = ConverterUnsupported
+	}
+	return node
+}
+
+func (e *explainer) explainStruct(node *PlanNode, srcType reflect.Type, dstType reflect.Type, options *Options, prefix string) {
+	pair := extractPairStructFieldFields(srcType, dstType, options, prefix)
+	hooks := newStructHooks(srcType, dstType)
+	for _, h := range []struct {
+		name string
+		has  bool
+	}{
+		{"BeforeConvTo", hooks.srcBefore},
+		{"BeforeConv", hooks.dstBefore},
+		{"AfterConv", hooks.dstAfter},
+		{"AfterConvTo", hooks.srcAfter},
+	} {
+		if h.has {
+			node.Hooks = append(node.Hooks, h.name)
+		}
+	}
+
+	for i := range pair.srcStruct.List {
+		if sf := &pair.srcStruct.List[i]; !sf.hidden && !sf.hasSetter {
+			node.SrcFields = append(node.SrcFields, sf.name)
+		}
+	}
+
+	for i := range pair.dstStruct.List {
+		df := &pair.dstStruct.List[i]
+		if df.hidden {
+			continue
+		}
+		fp := &FieldPlan{
+			Name:        df.name,
+			IgnoreEmpty: df.ignoreEmpty,
+			Validated:   df.validation != nil,
+		}
+		if df.alias != df.name {
+			fp.Alias = df.alias
+		}
+		if df.hasDefault {
+			fp.Default = fmt.Sprintf("%v", df.defaultValue)
+		}
+		if df.hasSetter {
+			fp.Setter = df.setter.Name
+		}
+		node.Fields = append(node.Fields, fp)
+
+		fieldOptions := options.split(df.alias)
+		if df.hasRefPolicy {
+			fieldOptions = fieldOptions.SetRefPolicy(df.refPolicy).Freeze()
+		}
+		switch {
+		case df.customConv:
+			fp.Source = SourceFunc
+			fp.Func = getFunctionName(df.converter) + " " + df.converter.Type().String()
+			continue
+		case df.param:
+			fp.Source = SourceParam
+			fp.Param = df.paramName
+			continue
+		case df.inline:
+			fp.Source = SourceInline
+			inline := &PlanNode{
+				SrcType:   srcType.String(),
+				DstType:   inlineStructType(df.tp).String(),
+				Kind:      reflect.Struct.String(),
+				Converter: ConverterInline,
+				DeepCopy:  fieldOptions.DeepCopy,
+			}
+			e.explainStruct(inline, srcType, inlineStructType(df.tp), fieldOptions, prefix+df.prefix)
+			fp.Plan = inline
+			continue
+		case len(df.from) > 0:
+			fp.Source = SourcePath
+			fp.SrcField = strings.Join(df.from, ".")
+		default:
+			if g, ok := pair.getters[df.alias]; ok {
+				fp.Source = SourceGetter
+				fp.SrcField = g.method.Name + "()"
+			} else if index, exist := pair.srcIndex(df.alias); exist {
+				fp.Source = SourceField
+				fp.SrcField = pair.srcStruct.List[index].name
+			} else if df.hasDefault {
+				fp.Source = SourceDefault
+				continue
+			} else {
+				fp.Source = SourceSetter
+				continue
+			}
+		}
+		if st := pair.fieldSrcType(srcType, df); st != nil {
+			fp.Plan = e.explain(st, df.tp, fieldOptions)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ssconv
+
+import (
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// getter is a zero-arg method of src type used as the source of a dst field.
+// The method returns the value, optionally followed by an error
+type getter struct {
+	method  reflect.Method
+	withErr bool
+}
+
+// findGetter looks up method name on src type, including methods of pointer receiver.
+// A method with wrong signature is reported as an error
+func findGetter(srcType reflect.Type, name string) (g getter, exist bool, err error) {
+	m, exist := reflect.PtrTo(srcType).MethodByName(name)
+	if !exist {
+		return g, false, nil
+	}
+	mt := m.Type
+	if mt.NumIn() != 1 { // receiver only
+		return g, true, fmt.Errorf("getter %s: method should have no argument", name)
+	}
+	switch {
+	case mt.NumOut() == 1 && mt.Out(0) != errorInterfaceType:
+	case mt.NumOut() == 2 && mt.Out(1) == errorInterfaceType:
+		g.withErr = true
+	default:
+		return g, true, fmt.Errorf("getter %s: method should return a value and an optional error", name)
+	}
+	g.method = m
+	return g, true, nil
+}
+
+// autoGetter matches a src method by alias of dst field, then by the exported
+// form of alias and the name of dst field
+func autoGetter(srcType reflect.Type, f *field) (getter, bool) {
+	for _, name := range []string{f.alias, exportedName(f.alias), f.name} {
+		if name == "" {
+			continue
+		}
+		g, exist, err := findGetter(srcType, name)
+		if exist && err == nil {
+			return g, true
+		}
+	}
+	return getter{}, false
+}
+
+func exportedName(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+func (g getter) get(src reflect.Value) reflect.Value {
+	ret := g.method.Func.Call([]reflect.Value{addressOf(src)})
+	if g.withErr && !ret[1].IsNil() {
+		convPanic(ret[1].Interface().(error))
+	}
+	return ret[0]
+}
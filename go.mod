@@ -4,6 +4,5 @@ go 1.16
 
 require (
 	github.com/google/go-cmp v0.5.7
-	github.com/mitchellh/hashstructure/v2 v2.0.2
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826
 )
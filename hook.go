@@ -0,0 +1,104 @@
+package ssconv
+
+import (
+	"reflect"
+)
+
+// BeforeConvHook is implemented by dst struct types that need to be prepared
+// before their fields are filled. src is the struct being converted from
+type BeforeConvHook interface {
+	BeforeConv(src interface{}, list ParamList) error
+}
+
+// AfterConvHook is implemented by dst struct types that normalise or derive
+// fields after all fields are filled
+type AfterConvHook interface {
+	AfterConv(src interface{}, list ParamList) error
+}
+
+// BeforeConvToHook is implemented by src struct types that need to be notified
+// before they are converted to dst
+type BeforeConvToHook interface {
+	BeforeConvTo(dst interface{}, list ParamList) error
+}
+
+// AfterConvToHook is implemented by src struct types that need to be notified
+// after they are converted to dst
+type AfterConvToHook interface {
+	AfterConvTo(dst interface{}, list ParamList) error
+}
+
+var (
+	beforeConvHookType   = reflect.TypeOf((*BeforeConvHook)(nil)).Elem()
+	afterConvHookType    = reflect.TypeOf((*AfterConvHook)(nil)).Elem()
+	beforeConvToHookType = reflect.TypeOf((*BeforeConvToHook)(nil)).Elem()
+	afterConvToHookType  = reflect.TypeOf((*AfterConvToHook)(nil)).Elem()
+)
+
+// structHooks records which hooks a src/dst struct pair implements,
+// it is computed once when the struct converter is built
+type structHooks struct {
+	dstBefore bool
+	dstAfter  bool
+	srcBefore bool
+	srcAfter  bool
+}
+
+func newStructHooks(srcType reflect.Type, dstType reflect.Type) structHooks {
+	dstPtr := reflect.PtrTo(dstType)
+	srcPtr := reflect.PtrTo(srcType)
+	return structHooks{
+		dstBefore: dstPtr.Implements(beforeConvHookType),
+		dstAfter:  dstPtr.Implements(afterConvHookType),
+		srcBefore: srcPtr.Implements(beforeConvToHookType),
+		srcAfter:  srcPtr.Implements(afterConvToHookType),
+	}
+}
+
+// addressOf returns a pointer to v, copying v when it is not addressable
+func addressOf(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v.Addr()
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr
+}
+
+func paramListOf(list reflect.Value) ParamList {
+	if !list.IsValid() {
+		return nil
+	}
+	pl, _ := list.Interface().(ParamList)
+	return pl
+}
+
+func (h structHooks) before(src reflect.Value, dst reflect.Value, list reflect.Value) {
+	if h.srcBefore {
+		err := addressOf(src).Interface().(BeforeConvToHook).BeforeConvTo(dst.Addr().Interface(), paramListOf(list))
+		if err != nil {
+			convPanic(&ErrHook{hook: "BeforeConvTo", tp: src.Type(), err: err})
+		}
+	}
+	if h.dstBefore {
+		err := dst.Addr().Interface().(BeforeConvHook).BeforeConv(src.Interface(), paramListOf(list))
+		if err != nil {
+			convPanic(&ErrHook{hook: "BeforeConv", tp: dst.Type(), err: err})
+		}
+	}
+}
+
+func (h structHooks) after(src reflect.Value, dst reflect.Value, list reflect.Value) {
+	if h.dstAfter {
+		err := dst.Addr().Interface().(AfterConvHook).AfterConv(src.Interface(), paramListOf(list))
+		if err != nil {
+			convPanic(&ErrHook{hook: "AfterConv", tp: dst.Type(), err: err})
+		}
+	}
+	if h.srcAfter {
+		err := addressOf(src).Interface().(AfterConvToHook).AfterConvTo(dst.Addr().Interface(), paramListOf(list))
+		if err != nil {
+			convPanic(&ErrHook{hook: "AfterConvTo", tp: src.Type(), err: err})
+		}
+	}
+}
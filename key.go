@@ -0,0 +1,60 @@
+package ssconv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// computeKey encodes op canonically. Groups and rules keep their order, since
+// later rules override earlier ones, and operations are sorted by name.
+// Funcs are identified by their closure, which can not be reused by another
+// func while a cached plan holds it
+func (op *Options) computeKey() string {
+	var b strings.Builder
+	b.WriteString("deep=" + strconv.FormatBool(op.DeepCopy))
+	b.WriteString(",unexported=" + strconv.FormatBool(op.Unexported))
+	b.WriteString(",nil=" + strconv.Itoa(int(op.NilPolicy)))
+	b.WriteString(",emptyNil=" + strconv.FormatBool(op.EmptyNil))
+	b.WriteString(",ref=" + strconv.Itoa(int(op.RefPolicy)))
+	for _, grp := range op.LocalRules {
+		fmt.Fprintf(&b, ";%q:", grp.Path)
+		for _, rule := range grp.Rules {
+			fmt.Fprintf(&b, "%q{", rule.Field)
+			names := make([]string, 0, len(rule.Operation))
+			for name := range rule.Operation {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(&b, "%q=%s,", name, operationKey(rule.Operation[name]))
+			}
+			b.WriteString("}")
+		}
+	}
+	return b.String()
+}
+
+func operationKey(v interface{}) string {
+	if fn, ok := v.(reflect.Value); ok {
+		if !fn.IsValid() {
+			return "func(nil)"
+		}
+		return fmt.Sprintf("func(%s)%#x", fn.Type(), funcPointer(fn))
+	}
+	return fmt.Sprintf("%T(%#v)", v, v)
+}
+
+// funcPointer returns the closure of fn, unlike fn.Pointer() it tells apart
+// closures sharing the same code
+func funcPointer(fn reflect.Value) uintptr {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return 0
+	}
+	p := reflect.New(fn.Type())
+	p.Elem().Set(fn)
+	return uintptr(*(*unsafe.Pointer)(unsafe.Pointer(p.Pointer())))
+}
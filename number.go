@@ -0,0 +1,79 @@
+package ssconv
+
+import (
+	"math"
+	"reflect"
+)
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// convertible reports whether values of src can be converted to dst by convertValue:
+// numbers of any kind, or basic types of the same kind like a named string and string
+func convertible(src reflect.Type, dst reflect.Type) bool {
+	if isNumber(src) && isNumber(dst) {
+		return true
+	}
+	switch src.Kind() {
+	case reflect.Bool, reflect.String, reflect.Complex64, reflect.Complex128:
+		return src.Kind() == dst.Kind()
+	}
+	return false
+}
+
+// convertValue converts src to the type of dst, numbers which do not fit dst
+// without loss are reported as ErrOverflow
+func convertValue(src reflect.Value, dst reflect.Value) {
+	if isNumber(src.Type()) && !fitNumber(src, dst.Type()) {
+		convPanic(&ErrOverflow{value: src, tp: dst.Type()})
+	}
+	dst.Set(src.Convert(dst.Type()))
+}
+
+// fitNumber reports whether number v is kept as it is in type t
+func fitNumber(v reflect.Value, t reflect.Type) bool {
+	zero := reflect.Zero(t)
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return !zero.OverflowInt(v.Int())
+		case reflect.Float32, reflect.Float64:
+			f := v.Float()
+			return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && !zero.OverflowInt(int64(f))
+		default:
+			return v.Uint() <= math.MaxInt64 && !zero.OverflowInt(int64(v.Uint()))
+		}
+	case reflect.Float32, reflect.Float64:
+		// the value must come back unchanged from t, which rules out
+		// rounding as well as overflow
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f := v.Float()
+			return t.Kind() == reflect.Float64 || math.IsNaN(f) || float64(float32(f)) == f
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f := reflect.ValueOf(v.Int()).Convert(t).Float()
+			return f >= math.MinInt64 && f < math.MaxInt64 && int64(f) == v.Int()
+		default:
+			f := reflect.ValueOf(v.Uint()).Convert(t).Float()
+			return f < math.MaxUint64 && uint64(f) == v.Uint()
+		}
+	default: // unsigned
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Int() >= 0 && !zero.OverflowUint(uint64(v.Int()))
+		case reflect.Float32, reflect.Float64:
+			f := v.Float()
+			return f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 && !zero.OverflowUint(uint64(f))
+		default:
+			return !zero.OverflowUint(v.Uint())
+		}
+	}
+}
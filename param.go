@@ -0,0 +1,61 @@
+package ssconv
+
+import (
+	"reflect"
+	"strings"
+)
+
+// splitParamPath splits a dotted param name like "user.profile.id" into the keys
+// used to walk nested values of a ParamList
+func splitParamPath(name string) []string {
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
+
+// lookupParam walks list along path, descending into maps with string keys and into
+// exported struct fields, through pointers and interfaces.
+// It returns an invalid Value if any step of the path is missing or nil
+func lookupParam(list reflect.Value, path []string) reflect.Value {
+	v := list
+	for _, key := range path {
+		v = indirectParam(v)
+		if !v.IsValid() {
+			return reflect.Value{}
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}
+			}
+			v = v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		case reflect.Struct:
+			sf, ok := v.Type().FieldByName(key)
+			if !ok || sf.PkgPath != "" {
+				return reflect.Value{}
+			}
+			v = v.FieldByIndex(sf.Index)
+		default:
+			return reflect.Value{}
+		}
+	}
+	// values stored in a ParamList are interface{}, convert the dynamic value
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func indirectParam(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,104 @@
+package ssconv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// splitSrcPath splits a from path like "user.profile.avatar" into aliases
+func splitSrcPath(from string) []string {
+	if from == "" {
+		return nil
+	}
+	return strings.Split(from, ".")
+}
+
+// checkSrcPath checks every step of path that can be known from srcType:
+// struct fields must exist and maps must have string keys.
+// It returns the type at the end of path, or nil if the path goes through an
+// interface and is only resolved at conversion
+func checkSrcPath(srcType reflect.Type, path []string) reflect.Type {
+	tp := srcType
+	for i, alias := range path {
+		for tp.Kind() == reflect.Ptr {
+			tp = tp.Elem()
+		}
+		switch tp.Kind() {
+		case reflect.Struct:
+			sf := cachedStructField(tp, nil)
+			index, exist := sf.sourceIndex(alias)
+			if !exist {
+				convPanicStr(fmt.Sprintf("from %s: field %s not exists", strings.Join(path, "."), alias))
+			}
+			tp = sf.List[index].tp
+		case reflect.Map:
+			if tp.Key().Kind() != reflect.String {
+				convPanicStr(fmt.Sprintf("from %s: key of %s is not string", strings.Join(path, "."), tp))
+			}
+			tp = tp.Elem()
+		case reflect.Interface:
+			return nil
+		default:
+			convPanicStr(fmt.Sprintf("from %s: cant find %s in %s", strings.Join(path, "."), strings.Join(path[i:], "."), tp))
+		}
+	}
+	if tp.Kind() == reflect.Interface {
+		return nil
+	}
+	return tp
+}
+
+// lookupSrcPath walks src along path by alias. It returns false when a pointer,
+// interface or map entry on the way is nil or missing
+func lookupSrcPath(src reflect.Value, path []string) (reflect.Value, bool) {
+	v := src
+	for _, alias := range path {
+		v = indirectParam(v)
+		if !v.IsValid() {
+			return v, false
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			sf := cachedStructField(v.Type(), nil)
+			index, exist := sf.sourceIndex(alias)
+			if !exist {
+				convPanicStr(fmt.Sprintf("from %s: field %s not exists in %s", strings.Join(path, "."), alias, v.Type()))
+			}
+			var ok bool
+			v, ok = fieldByIndex(v, sf.List[index].index)
+			if !ok {
+				return v, false
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				convPanicStr(fmt.Sprintf("from %s: key of %s is not string", strings.Join(path, "."), v.Type()))
+			}
+			v = v.MapIndex(reflect.ValueOf(alias).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return v, false
+			}
+		default:
+			convPanicStr(fmt.Sprintf("from %s: cant find %s in %s", strings.Join(path, "."), alias, v.Type()))
+		}
+	}
+	for v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// inlineStructType returns the struct type of an inline field of type tp,
+// which is a struct or a pointer to struct. It returns nil for other types
+func inlineStructType(tp reflect.Type) reflect.Type {
+	if tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp.Kind() != reflect.Struct {
+		return nil
+	}
+	return tp
+}
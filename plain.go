@@ -0,0 +1,147 @@
+package ssconv
+
+import (
+	"reflect"
+	"strings"
+)
+
+// plainType reports whether values of t hold no pointer, map, slice, interface,
+// func or chan, so that a copy of the value is also a deep copy
+func plainType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128,
+		reflect.String:
+		return true
+	case reflect.Array:
+		return plainType(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !plainType(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// leafFields counts the fields of t, fields of embedded structs are counted
+// in place of the embedded struct
+func leafFields(t reflect.Type) int {
+	n := 0
+	for i := 0; i < t.NumField(); i++ {
+		ts := t.Field(i)
+		if ts.Anonymous && ts.Type.Kind() == reflect.Struct {
+			n += leafFields(ts.Type)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// plainStruct reports whether converting a plain struct t to itself field by
+// field gives the same result as copying the value: every field is matched by
+// itself and no tag option, setter or hook is involved
+func plainStruct(t reflect.Type, options *Options) bool {
+	if newStructHooks(t, t) != (structHooks{}) {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	for i := 0; i < pt.NumMethod(); i++ {
+		if m := pt.Method(i); strings.HasPrefix(m.Name, "Set") && checkSetter(m) {
+			return false
+		}
+	}
+
+	list := cachedStructField(t, options).List
+	if len(list) != leafFields(t) { // some fields are skipped or ambiguous
+		return false
+	}
+	for i := range list {
+		f := &list[i]
+		if f.hidden || f.ignoreEmpty || f.param || f.customConv || f.hasDefault || f.validation != nil ||
+			f.getter != "" || f.hasSetter || len(f.from) > 0 || f.inline {
+			return false
+		}
+		if f.tp.Kind() == reflect.Struct && !plainStruct(f.tp, options) {
+			return false
+		}
+	}
+	return true
+}
+
+// newPlainConverter returns a converter copying values of t as a whole, or nil
+// if t needs to be converted field by field or element by element
+func newPlainConverter(t reflect.Type, options *Options) convFunc {
+	if len(options.LocalRules) > 0 {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if plainType(t) && plainStruct(t, options) {
+			fields := plainFieldsOf(t, options)
+			return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
+				dst.Set(src)
+				c.recordPlain(fields)
+			}
+		}
+	case reflect.Slice:
+		elem := t.Elem()
+		if options.DeepCopy && plainType(elem) && (elem.Kind() != reflect.Struct || plainStruct(elem, options)) {
+			var fields []plainField
+			if elem.Kind() == reflect.Struct {
+				fields = plainFieldsOf(elem, options)
+			}
+			return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
+				dst.Set(reflect.MakeSlice(dst.Type(), src.Len(), src.Len()))
+				reflect.Copy(dst, src)
+				if c.report != nil && fields != nil {
+					for i := 0; i < src.Len(); i++ {
+						c.enterIndex(i)
+						c.recordPlain(fields)
+						c.leave()
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// plainField is a field of a plain struct, reported as if it was converted
+// field by field
+type plainField struct {
+	field  *field
+	nested []plainField // fields of a nested struct
+}
+
+func plainFieldsOf(t reflect.Type, options *Options) []plainField {
+	list := cachedStructField(t, options).List
+	ret := make([]plainField, len(list))
+	for i := range list {
+		ret[i].field = &list[i]
+		if list[i].tp.Kind() == reflect.Struct {
+			ret[i].nested = plainFieldsOf(list[i].tp, options)
+		}
+	}
+	return ret
+}
+
+// recordPlain reports fields copied with their struct, each one from itself
+func (c *convState) recordPlain(fields []plainField) {
+	if c.report == nil {
+		return
+	}
+	for _, f := range fields {
+		c.record(f.field, SourceField, f.field.name, OutcomeSet)
+		if f.nested != nil {
+			c.enter(f.field.name, f.field.name)
+			c.recordPlain(f.nested)
+			c.leave()
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package ssconv
+
+import (
+	"reflect"
+)
+
+// newUnwrapConverter converts a pointer src to a dst of lesser pointer depth by
+// dereferencing src, nil src is handled by the nil guard of newConv
+func newUnwrapConverter(srcType reflect.Type, dstType reflect.Type, options *Options) convFunc {
+	elemConv := cacheConverter(srcType.Elem(), dstType, options)
+	return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
+		elemConv(c, src.Elem(), dst, list)
+	}
+}
+
+// newWrapConverter converts src to a pointer dst of greater pointer depth,
+// the value dst points to is allocated if dst is nil
+func newWrapConverter(srcType reflect.Type, dstType reflect.Type, options *Options) convFunc {
+	elemConv := cacheConverter(srcType, dstType.Elem(), options)
+	return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		elemConv(c, src, dst.Elem(), list)
+	}
+}
+
+// ptrDepth returns the number of pointers to dereference to reach a non-pointer type
+func ptrDepth(t reflect.Type) int {
+	n := 0
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,171 @@
+package ssconv
+
+import (
+	"reflect"
+)
+
+// NilPolicy decides what happens to a dst whose source is nil: a nil pointer,
+// map, slice or interface, or a nil value on the way of a src path
+type NilPolicy int
+
+const (
+	// NilDefault fails the conversion when nil has to be dereferenced, like a
+	// nil value on the way of a path or a nil pointer to a value, and sets dst
+	// to nil when dst can hold nil. It is the default policy
+	NilDefault NilPolicy = iota
+	// NilError fails the conversion on every nil source
+	NilError
+	// NilSkip leaves dst untouched
+	NilSkip
+	// NilZero sets dst to its zero value
+	NilZero
+)
+
+func (p NilPolicy) String() string {
+	switch p {
+	case NilDefault:
+		return "default"
+	case NilError:
+		return "error"
+	case NilSkip:
+		return "skip"
+	case NilZero:
+		return "zero"
+	}
+	return "unknown"
+}
+
+// nilPolicyOf parses the value of a nil tag option or LocalRule operation
+func nilPolicyOf(v interface{}) NilPolicy {
+	switch p := v.(type) {
+	case NilPolicy:
+		return p
+	case string:
+		for _, policy := range []NilPolicy{NilDefault, NilError, NilSkip, NilZero} {
+			if policy.String() == p {
+				return policy
+			}
+		}
+	}
+	convPanicStr("nil: policy should be one of default, error, skip, zero")
+	return NilDefault
+}
+
+// canBeNil reports whether values of t can be nil sources
+func canBeNil(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// canBeEmpty reports whether values of t are slices or maps
+func canBeEmpty(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+}
+
+// newNilGuard wraps f, the converter of a src type that can be nil,
+// nil sources are handled by policy instead of f, or set to empty if empty is true
+func newNilGuard(f convFunc, policy NilPolicy, empty bool) convFunc {
+	return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
+		if !src.IsNil() {
+			f(c, src, dst, list)
+			return
+		}
+		if empty {
+			if dst.Kind() == reflect.Slice {
+				dst.Set(reflect.MakeSlice(dst.Type(), 0, 0))
+			} else {
+				dst.Set(reflect.MakeMap(dst.Type()))
+			}
+			return
+		}
+		applyNilValue(policy, src, dst)
+	}
+}
+
+// applyNilValue sets dst converted from the nil src by policy, it reports whether dst is written
+func applyNilValue(policy NilPolicy, src reflect.Value, dst reflect.Value) bool {
+	switch policy {
+	case NilSkip:
+		return false
+	case NilZero:
+		dst.Set(reflect.Zero(dst.Type()))
+		return true
+	case NilDefault:
+		if canBeNil(dst.Type()) {
+			dst.Set(reflect.Zero(dst.Type()))
+			return true
+		}
+	}
+	convPanic(&ErrNilSrcPtr{src: src})
+	return false
+}
+
+// RefPolicy decides what happens to func, chan and unsafe.Pointer values,
+// which can only be copied by reference
+type RefPolicy int
+
+const (
+	// RefCopy copies the reference when src is assignable to dst, it is the default policy
+	RefCopy RefPolicy = iota
+	// RefSkip leaves dst untouched
+	RefSkip
+	// RefError fails the conversion
+	RefError
+)
+
+func (p RefPolicy) String() string {
+	switch p {
+	case RefCopy:
+		return "copy"
+	case RefSkip:
+		return "skip"
+	case RefError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// refPolicyOf parses the value of a ref tag option or LocalRule operation
+func refPolicyOf(v interface{}) RefPolicy {
+	switch p := v.(type) {
+	case RefPolicy:
+		return p
+	case string:
+		for _, policy := range []RefPolicy{RefCopy, RefSkip, RefError} {
+			if policy.String() == p {
+				return policy
+			}
+		}
+	}
+	convPanicStr("ref: policy should be one of copy, skip, error")
+	return RefCopy
+}
+
+// isRef reports whether values of t are only copied by reference
+func isRef(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+// newRefConverter returns the converter of reference dst type by policy,
+// references which can not be copied are reported when the plan is built
+func newRefConverter(srcType reflect.Type, dstType reflect.Type, policy RefPolicy) convFunc {
+	switch policy {
+	case RefSkip:
+		return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {}
+	case RefError:
+		convPanic(&ErrReference{tp: dstType})
+	}
+	if !srcType.AssignableTo(dstType) {
+		convPanic(&ErrUnableAssignType{srcType, dstType})
+	}
+	return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,208 @@
+package ssconv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// planGraph is the struct pairing of plans as nodes of struct types and edges
+// from src fields to dst fields. Struct types shared by plans are drawn once as
+// src and once as dst, so a type converted to itself has separate nodes
+type planGraph struct {
+	structs []*graphStruct
+	byType  map[graphKey]*graphStruct
+	edges   []graphEdge
+	seen    map[graphEdge]bool
+	params  bool // a param is used, the param list is drawn as a node
+}
+
+type graphKey struct {
+	dst bool
+	tp  string
+}
+
+type graphStruct struct {
+	id     string
+	tp     string
+	fields []string
+}
+
+// graphEdge goes to field to of dst. from is empty when the value comes from
+// src as a whole, like funcs, getters and paths, and src is nil when it comes
+// from the param list
+type graphEdge struct {
+	src   *graphStruct
+	from  string
+	dst   *graphStruct
+	to    string
+	label string
+}
+
+func newPlanGraph(plans []*PlanNode) *planGraph {
+	g := &planGraph{byType: make(map[graphKey]*graphStruct), seen: make(map[graphEdge]bool)}
+	for _, p := range plans {
+		g.add(p)
+	}
+	return g
+}
+
+func (g *planGraph) structOf(dst bool, tp string, fields []string) *graphStruct {
+	key := graphKey{dst: dst, tp: tp}
+	s, ok := g.byType[key]
+	if !ok {
+		s = &graphStruct{id: fmt.Sprintf("n%d", len(g.structs)), tp: tp}
+		g.byType[key] = s
+		g.structs = append(g.structs, s)
+	}
+	for _, f := range fields {
+		if !containsString(s.fields, f) {
+			s.fields = append(s.fields, f)
+		}
+	}
+	return s
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *planGraph) add(n *PlanNode) {
+	for n != nil && n.Elem != nil { // elements of pointers, slices and maps, wrapped and unwrapped values
+		n = n.Elem
+	}
+	if n == nil || n.Recursive || (n.Converter != ConverterStruct && n.Converter != ConverterInline) {
+		return
+	}
+
+	src := g.structOf(false, n.SrcType, n.SrcFields)
+	var dstFields []string
+	for _, fp := range n.Fields {
+		dstFields = append(dstFields, fp.Name)
+	}
+	dst := g.structOf(true, n.DstType, dstFields)
+
+	for _, fp := range n.Fields {
+		e := graphEdge{src: src, dst: dst, to: fp.Name, label: edgeLabel(fp)}
+		switch fp.Source {
+		case SourceField:
+			e.from = fp.SrcField
+		case SourceParam:
+			e.src = nil
+			g.params = true
+		case SourceDefault, SourceSetter: // nothing flows into the field
+			g.add(fp.Plan)
+			continue
+		}
+		if !g.seen[e] {
+			g.seen[e] = true
+			g.edges = append(g.edges, e)
+		}
+		g.add(fp.Plan)
+	}
+}
+
+// edgeLabel annotates how the value of fp is taken
+func edgeLabel(fp *FieldPlan) string {
+	var ann []string
+	switch fp.Source {
+	case SourceFunc:
+		name := strings.SplitN(fp.Func, " ", 2)[0]
+		ann = append(ann, "func "+name[strings.LastIndex(name, "/")+1:])
+	case SourceParam:
+		ann = append(ann, "param "+fp.Param)
+	case SourceGetter:
+		ann = append(ann, "getter "+fp.SrcField)
+	case SourcePath:
+		ann = append(ann, "from "+fp.SrcField)
+	case SourceInline:
+		ann = append(ann, "inline")
+	}
+	if fp.IgnoreEmpty {
+		ann = append(ann, "ignoreEmpty")
+	}
+	if fp.Default != "" {
+		ann = append(ann, "default="+fp.Default)
+	}
+	if fp.Setter != "" {
+		ann = append(ann, "setter "+fp.Setter)
+	}
+	return strings.Join(ann, ", ")
+}
+
+// RenderDOT draws the struct pairings of plans returned by Explain as a Graphviz digraph
+func RenderDOT(plans ...*PlanNode) string {
+	g := newPlanGraph(plans)
+	var b strings.Builder
+	b.WriteString("digraph ssconv {\n\trankdir=LR;\n\tnode [shape=record];\n")
+	if g.params {
+		b.WriteString("\tparams [shape=ellipse, label=\"ParamList\"];\n")
+	}
+	for _, s := range g.structs {
+		cells := []string{dotEscape(s.tp)}
+		for _, f := range s.fields {
+			cells = append(cells, fmt.Sprintf("<%s> %s", f, dotEscape(f)))
+		}
+		fmt.Fprintf(&b, "\t%s [label=\"{%s}\"];\n", s.id, strings.Join(cells, "|"))
+	}
+	for _, e := range g.edges {
+		from := "params"
+		if e.src != nil {
+			from = e.src.id
+			if e.from != "" {
+				from += ":" + e.from
+			}
+		}
+		fmt.Fprintf(&b, "\t%s -> %s:%s", from, e.dst.id, e.to)
+		if e.label != "" {
+			fmt.Fprintf(&b, " [label=\"%s\"]", dotEscape(e.label))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`).Replace(s)
+}
+
+// RenderMermaid draws the struct pairings of plans returned by Explain as a Mermaid flowchart
+func RenderMermaid(plans ...*PlanNode) string {
+	g := newPlanGraph(plans)
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	if g.params {
+		b.WriteString("\tparams([\"ParamList\"])\n")
+	}
+	for _, s := range g.structs {
+		fmt.Fprintf(&b, "\tsubgraph %s[\"%s\"]\n", s.id, mermaidEscape(s.tp))
+		for _, f := range s.fields {
+			fmt.Fprintf(&b, "\t\t%s_%s[\"%s\"]\n", s.id, f, mermaidEscape(f))
+		}
+		b.WriteString("\tend\n")
+	}
+	for _, e := range g.edges {
+		from := "params"
+		if e.src != nil {
+			from = e.src.id
+			if e.from != "" {
+				from += "_" + e.from
+			}
+		}
+		arrow := "-->"
+		if e.label != "" {
+			arrow = fmt.Sprintf("-->|\"%s\"|", mermaidEscape(e.label))
+		}
+		fmt.Fprintf(&b, "\t%s %s %s_%s\n", from, arrow, e.dst.id, e.to)
+	}
+	return b.String()
+}
+
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
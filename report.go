@@ -0,0 +1,109 @@
+package ssconv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Report tells how every dst field reached by a conversion is filled,
+// fields of nested structs and elements are reported with their full path
+type Report struct {
+	Fields []FieldReport `json:"fields"`
+}
+
+// FieldReport is the outcome of one dst field
+type FieldReport struct {
+	Path    string `json:"path"`           // dst path like Author.Avatar or Replies[0].ID
+	Source  string `json:"source"`         // one of the Source* names
+	From    string `json:"from,omitempty"` // src path, getter, param name or func name
+	Outcome string `json:"outcome"`        // one of the Outcome* names
+}
+
+// outcomes of dst fields in FieldReport.Outcome
+const (
+	OutcomeSet     = "set"     // converted from its source
+	OutcomeDefault = "default" // the default value is set
+	OutcomeSkipped = "skipped" // left untouched: empty and ignoreEmpty, missing param or nil source skipped
+	OutcomeZero    = "zero"    // nil source, the zero value is set
+)
+
+// Field returns the report of dst field path
+func (r *Report) Field(path string) (FieldReport, bool) {
+	for _, f := range r.Fields {
+		if f.Path == path {
+			return f, true
+		}
+	}
+	return FieldReport{}, false
+}
+
+// ConvReport converts like Conv and also returns the report of dst fields.
+// The report is returned as far as the conversion went when err is not nil
+func ConvReport(src interface{}, dst interface{}, options *Options, list ParamList) (report *Report, err error) {
+	report = new(Report)
+	return report, conv(src, dst, options, list, report)
+}
+
+func (c *convState) record(df *field, source string, from string, outcome string) {
+	if c.report == nil {
+		return
+	}
+	if from != "" && source != SourceParam && source != SourceFunc {
+		from = joinPath(append(c.src, from))
+	}
+	c.report.Fields = append(c.report.Fields, FieldReport{
+		Path:    joinPath(append(c.path, df.name)),
+		Source:  source,
+		From:    from,
+		Outcome: outcome,
+	})
+}
+
+// recordNil records a field whose source is nil, handled by nil policy
+func (c *convState) recordNil(df *field, source string, from string, written bool) {
+	outcome := OutcomeSkipped
+	if written {
+		outcome = OutcomeZero
+	}
+	c.record(df, source, from, outcome)
+}
+
+// enter descends into dst field or element dst converted from src
+func (c *convState) enter(dst string, src string) {
+	if c.report == nil {
+		return
+	}
+	c.path = append(c.path, dst)
+	c.src = append(c.src, src)
+}
+
+func (c *convState) leave() {
+	if c.report == nil {
+		return
+	}
+	c.path = c.path[:len(c.path)-1]
+	c.src = c.src[:len(c.src)-1]
+}
+
+func (c *convState) enterIndex(index interface{}) {
+	if c.report == nil {
+		return
+	}
+	s := fmt.Sprintf("[%v]", index)
+	c.enter(s, s)
+}
+
+// joinPath joins names with dots, indexes like [0] are appended as they are
+func joinPath(parts []string) string {
+	var b strings.Builder
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		if b.Len() > 0 && !strings.HasPrefix(p, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
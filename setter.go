@@ -0,0 +1,68 @@
+package ssconv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// checkSetter reports whether m takes exactly one argument and returns nothing or an error
+func checkSetter(m reflect.Method) bool {
+	mt := m.Type
+	if mt.NumIn() != 2 { // receiver and value
+		return false
+	}
+	return mt.NumOut() == 0 || (mt.NumOut() == 1 && mt.Out(0) == errorInterfaceType)
+}
+
+// findSetter looks up setter name on *t, the setter named in a tag must exist
+func findSetter(t reflect.Type, name string) reflect.Method {
+	m, exist := reflect.PtrTo(t).MethodByName(name)
+	if !exist {
+		convPanicStr(fmt.Sprintf("setter %s not exists", name))
+	}
+	if !checkSetter(m) {
+		convPanicStr(fmt.Sprintf("setter %s: method should take one argument and return an optional error", name))
+	}
+	return m
+}
+
+// autoSetterFields returns a field for every SetX method of *t whose X is not
+// the alias or name of any field in fields
+func autoSetterFields(t reflect.Type, fields []field) []field {
+	taken := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		taken[f.alias] = true
+		taken[f.name] = true
+	}
+
+	var ret []field
+	pt := reflect.PtrTo(t)
+	for i := 0; i < pt.NumMethod(); i++ {
+		m := pt.Method(i)
+		if !strings.HasPrefix(m.Name, "Set") || len(m.Name) == len("Set") || !checkSetter(m) {
+			continue
+		}
+		alias := m.Name[len("Set"):]
+		if taken[alias] {
+			continue
+		}
+		ret = append(ret, field{
+			name:       m.Name,
+			alias:      alias,
+			tp:         m.Type.In(1),
+			setter:     m,
+			hasSetter:  true,
+			autoSetter: true,
+		})
+	}
+	return ret
+}
+
+// callSetter passes v to setter of dst, non-nil error is panicked
+func callSetter(setter reflect.Method, dst reflect.Value, v reflect.Value) {
+	ret := setter.Func.Call([]reflect.Value{dst.Addr(), v})
+	if len(ret) == 1 && !ret[0].IsNil() {
+		convPanic(ret[0].Interface().(error))
+	}
+}
@@ -4,14 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/mitchellh/hashstructure/v2"
 	"github.com/mohae/deepcopy"
-	"os"
 	"reflect"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type CustomFunc func(data interface{}, param map[string]interface{}) (result interface{}, err error)
@@ -26,7 +25,14 @@ type LocalRule struct {
 func (rule *LocalRule) clone() *LocalRule {
 	newrule := new(LocalRule)
 	newrule.Field = rule.Field
-	newrule.Operation = deepcopy.Copy(rule.Operation).(map[string]interface{})
+	newrule.Operation = make(map[string]interface{}, len(rule.Operation))
+	for k, v := range rule.Operation {
+		if _, ok := v.(reflect.Value); ok { // funcs are shared, deepcopy would lose them
+			newrule.Operation[k] = v
+			continue
+		}
+		newrule.Operation[k] = deepcopy.Copy(v)
+	}
 	return newrule
 }
 
@@ -43,10 +49,10 @@ func NewLocalRuleGroup(path string) *LocalRuleGroup {
 
 func (grp *LocalRuleGroup) AddRule(field string, operation map[string]interface{}) *LocalRuleGroup {
 
-	// convert function to value to hash
+	// convert function to value, identified in the key of Options by its closure
 	ope := deepcopy.Copy(operation).(map[string]interface{})
 	for k, v := range ope {
-		if reflect.TypeOf(v).Kind() == reflect.Func {
+		if v != nil && reflect.TypeOf(v).Kind() == reflect.Func {
 			ope[k] = reflect.ValueOf(v)
 		}
 	}
@@ -66,66 +72,213 @@ func (grp *LocalRuleGroup) clone() *LocalRuleGroup {
 	return newgrp
 }
 
+// Options controls a conversion. Setters change op in place, except on a
+// frozen Options where they return a modified copy.
+// Fields should be changed through the setters. Fields written directly are
+// noticed when op is used again, at the cost of freezing a new copy on every
+// use of a frozen op. A LocalRuleGroup must not be changed once added
 type Options struct {
 	DeepCopy   bool
+	Unexported bool // convert unexported fields as well
+	NilPolicy  NilPolicy
+	EmptyNil   bool // convert nil slices and maps to empty ones instead of applying NilPolicy
+	RefPolicy  RefPolicy
 	LocalRules []*LocalRuleGroup
-	hashCode   uint64
+	Tracer     Tracer // receives diagnostic events, it does not change the plan
+	keyCode    string
+	frozen     bool
+	keyFlags   optionFlags  // fields keyCode is computed from
+	snapshot   atomic.Value // *optionSnapshot of an unfrozen op returned by Freeze
 }
 
+// optionFlags records the fields an Options was frozen with,
+// to tell them apart from fields written directly later
+type optionFlags struct {
+	deepCopy   bool
+	unexported bool
+	nilPolicy  NilPolicy
+	emptyNil   bool
+	refPolicy  RefPolicy
+	localRules []*LocalRuleGroup
+}
+
+// optionSnapshot is the frozen copy of an unfrozen Options and the fields it was copied from
+type optionSnapshot struct {
+	options *Options
+	flags   optionFlags
+}
+
+// emptyOptions is used when Conv is given nil options
+var emptyOptions = new(Options).freeze()
+
 func (op *Options) AddLocalRule(group *LocalRuleGroup) *Options {
+	op = op.mutable()
 	op.LocalRules = append(op.LocalRules, group)
 	return op
 }
 
 func (op *Options) SetDeepcopy(dc bool) *Options {
+	op = op.mutable()
 	op.DeepCopy = dc
 	return op
 }
 
+// SetNilPolicy sets what to do when a source is nil
+func (op *Options) SetNilPolicy(policy NilPolicy) *Options {
+	op = op.mutable()
+	op.NilPolicy = policy
+	return op
+}
+
+// SetRefPolicy sets what to do with func, chan and unsafe.Pointer values
+func (op *Options) SetRefPolicy(policy RefPolicy) *Options {
+	op = op.mutable()
+	op.RefPolicy = policy
+	return op
+}
+
+// SetEmptyNil sets whether nil slices and maps become empty ones in dst,
+// for APIs requiring [] and {} rather than null
+func (op *Options) SetEmptyNil(emptyNil bool) *Options {
+	op = op.mutable()
+	op.EmptyNil = emptyNil
+	return op
+}
+
+// SetUnexported sets whether unexported fields are converted, they are skipped by default
+func (op *Options) SetUnexported(unexported bool) *Options {
+	op = op.mutable()
+	op.Unexported = unexported
+	return op
+}
+
+func (op *Options) SetDeepCode(deepCopy bool) *Options {
+	op = op.mutable()
+	op.DeepCopy = deepCopy
+	return op
+}
+
+// SetTracer sets the Tracer of conversions with op, overriding the global one
+func (op *Options) SetTracer(t Tracer) *Options {
+	op = op.mutable()
+	op.Tracer = t
+	return op
+}
+
+// Freeze returns an immutable copy of op whose key is computed once, it can be
+// shared by goroutines and reused across conversions without being copied again.
+// The copy is kept by op and returned again until op is changed.
+// Freeze of a frozen Options returns itself, or a new copy if its fields were written
+func (op *Options) Freeze() *Options {
+	if op == nil {
+		return emptyOptions
+	}
+	if op.frozen {
+		if !op.keyFlags.sameAs(op) {
+			return op.clone().freeze()
+		}
+		return op
+	}
+	if snapshot, _ := op.snapshot.Load().(*optionSnapshot); snapshot != nil && snapshot.flags.sameAs(op) {
+		return snapshot.options
+	}
+	snapshot := &optionSnapshot{options: op.clone().freeze(), flags: op.flags()}
+	op.snapshot.Store(snapshot)
+	return snapshot.options
+}
+
+func (op *Options) flags() optionFlags {
+	return optionFlags{
+		deepCopy:   op.DeepCopy,
+		unexported: op.Unexported,
+		nilPolicy:  op.NilPolicy,
+		emptyNil:   op.EmptyNil,
+		refPolicy:  op.RefPolicy,
+		localRules: append([]*LocalRuleGroup(nil), op.LocalRules...),
+	}
+}
+
+// sameAs reports whether the fields of op are still those recorded in f
+func (f *optionFlags) sameAs(op *Options) bool {
+	if f.deepCopy != op.DeepCopy || f.unexported != op.Unexported || f.nilPolicy != op.NilPolicy ||
+		f.emptyNil != op.EmptyNil || f.refPolicy != op.RefPolicy || len(f.localRules) != len(op.LocalRules) {
+		return false
+	}
+	for i, grp := range f.localRules {
+		if grp != op.LocalRules[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Frozen reports whether op is immutable
+func (op *Options) Frozen() bool {
+	return op != nil && op.frozen
+}
+
+// freeze computes the key of op and marks it immutable, op must not be shared yet
+func (op *Options) freeze() *Options {
+	op.keyCode = op.computeKey()
+	op.keyFlags = op.flags()
+	op.frozen = true
+	return op
+}
+
+// mutable returns op itself, or a copy of it to modify if op is frozen.
+// The frozen copy kept by op is dropped as op is about to change
+func (op *Options) mutable() *Options {
+	if op.frozen {
+		return op.clone()
+	}
+	op.snapshot.Store((*optionSnapshot)(nil))
+	return op
+}
+
 func (op *Options) clone() *Options {
 	newop := new(Options)
 	newop.DeepCopy = op.DeepCopy
+	newop.Unexported = op.Unexported
+	newop.NilPolicy = op.NilPolicy
+	newop.EmptyNil = op.EmptyNil
+	newop.RefPolicy = op.RefPolicy
+	newop.Tracer = op.Tracer
 	for _, grp := range op.LocalRules {
 		newop.LocalRules = append(newop.LocalRules, grp.clone())
 	}
 	return newop
 }
 
-func (op *Options) hash() uint64 {
+// key identifies op in the plan caches, it is only stored by frozen Options
+func (op *Options) key() string {
 	if op == nil {
-		return 0
+		return ""
 	}
-	if op.hashCode != 0 {
-		return op.hashCode
+	if op.frozen {
+		return op.keyCode
 	}
-	hashCode, err := hashstructure.Hash(op, hashstructure.FormatV2, nil)
-	if err != nil {
-		convPanic(err)
-	}
-	op.hashCode = hashCode
-	return op.hashCode
+	return op.computeKey()
 }
 
-func (op *Options) redirect(path string) *Options {
-	if op == nil {
-		return op
-	}
-	for _, localRule := range op.LocalRules {
-		if len(localRule.Path) < len(path) || localRule.Path[0:len(path)] != path {
-			convPanicStr("invalid path")
-		}
-		tail := 0
-		if len(localRule.Path) > len(path) {
-			if localRule.Path[len(path)] != '.' {
-				convPanicStr("invalid path")
-			}
-			tail = 1
-		}
-		localRule.Path = localRule.Path[len(path)+tail:]
-	}
-	return op
+// withTracer returns frozen op with Tracer t. Plans are shared by Options
+// differing only in Tracer, those built for a call trace to the Tracer of the call
+func (op *Options) withTracer(t Tracer) *Options {
+	op = op.Freeze()
+	ret := new(Options)
+	ret.DeepCopy = op.DeepCopy
+	ret.Unexported = op.Unexported
+	ret.NilPolicy = op.NilPolicy
+	ret.EmptyNil = op.EmptyNil
+	ret.RefPolicy = op.RefPolicy
+	ret.LocalRules = op.LocalRules
+	ret.Tracer = t
+	ret.keyCode = op.key()
+	ret.keyFlags = op.keyFlags
+	ret.frozen = true
+	return ret
 }
 
+// effect returns the frozen Options holding the rules of the current level
 func (op *Options) effect() *Options {
 	if op == nil {
 		return nil
@@ -140,24 +293,39 @@ func (op *Options) effect() *Options {
 	ret := new(Options)
 	ret.LocalRules = effectRule
 	ret.DeepCopy = op.DeepCopy
-	return ret
+	ret.Unexported = op.Unexported
+	ret.NilPolicy = op.NilPolicy
+	ret.EmptyNil = op.EmptyNil
+	ret.RefPolicy = op.RefPolicy
+	ret.Tracer = op.Tracer
+	return ret.freeze()
 }
 
+// split returns the frozen Options seen by field s: rules under path s with
+// the path relative to s. op is left untouched
 func (op *Options) split(s string) *Options {
-	var splitRule []*LocalRuleGroup
-	for _, localRule := range op.LocalRules {
-		if len(localRule.Path) > len(s) && localRule.Path[0:len(s)] != s {
-			splitRule = append(splitRule, localRule.clone())
-		}
+	if op == nil {
+		return nil
 	}
 	res := new(Options)
 	res.DeepCopy = op.DeepCopy
-	return res
-}
-
-func (op *Options) SetDeepCode(deepCopy bool) *Options {
-	op.DeepCopy = deepCopy
-	return op
+	res.Unexported = op.Unexported
+	res.NilPolicy = op.NilPolicy
+	res.EmptyNil = op.EmptyNil
+	res.RefPolicy = op.RefPolicy
+	res.Tracer = op.Tracer
+	for _, localRule := range op.LocalRules {
+		var path string
+		switch {
+		case localRule.Path == s:
+		case strings.HasPrefix(localRule.Path, s+"."):
+			path = localRule.Path[len(s)+1:]
+		default:
+			continue
+		}
+		res.LocalRules = append(res.LocalRules, &LocalRuleGroup{Path: path, Rules: localRule.Rules})
+	}
+	return res.freeze()
 }
 
 func (op *Options) IsEmpty() bool {
@@ -167,11 +335,20 @@ func (op *Options) IsEmpty() bool {
 type ParamList map[string]interface{}
 
 type convState struct { // TODO detect pointer circle
+	tracer Tracer
+	report *Report // nil unless the call asks for a report
+	path   []string
+	src    []string
 }
 
 type convFunc func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value)
 
 func Conv(src interface{}, dst interface{}, options *Options, list ParamList) (err error) {
+	return conv(src, dst, options, list, nil)
+}
+
+// conv runs a conversion, filling report if it is not nil
+func conv(src interface{}, dst interface{}, options *Options, list ParamList, report *Report) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if convErr, ok := r.(ConvErr); ok {
@@ -189,17 +366,29 @@ func Conv(src interface{}, dst interface{}, options *Options, list ParamList) (e
 		convPanic(errors.New(ErrDstNotAddressable))
 	}
 
+	tracer := options.tracer()
+	options = options.Freeze()
+
 	srcType := srcValue.Type()
 	dstType := dstValue.Type()
 	//fmt.Fprintln(os.Stderr, "1",dstType,srcType)
 	//fmt.Fprint()
 
-	c := new(convState)
-	cacheConverter(srcType, dstType, options)(c, srcValue, dstValue, reflect.ValueOf(list))
+	c := &convState{tracer: tracer, report: report}
+	tracedConverter(srcType, dstType, options, tracer)(c, srcValue, dstValue, reflect.ValueOf(list))
 	return nil
 }
 
 func newConv(srcType, dstType reflect.Type, options *Options) convFunc {
+	f := newValueConv(srcType, dstType, options)
+	if canBeNil(srcType) {
+		return newNilGuard(f, options.NilPolicy, options.EmptyNil && canBeEmpty(srcType) && canBeEmpty(dstType))
+	}
+	return f
+}
+
+// newValueConv returns the converter of non-nil values of srcType
+func newValueConv(srcType, dstType reflect.Type, options *Options) convFunc {
 
 	/*if dstType.Kind() != reflect.Ptr && dstType.Kind() != reflect.Map && dstType.Kind() != reflect.Slice {
 		panic(ErrDstTypeNotReference)
@@ -207,6 +396,23 @@ func newConv(srcType, dstType reflect.Type, options *Options) convFunc {
 
 	//fmt.Fprintln(os.Stderr, dstType,srcType)
 
+	// values of identical types holding no reference are copied as a whole
+	if srcType == dstType {
+		if f := newPlainConverter(dstType, options); f != nil {
+			return f
+		}
+	}
+
+	// pointers are dereferenced or allocated until src and dst have the same depth
+	if srcType != dstType && srcType.Kind() != reflect.Interface && dstType.Kind() != reflect.Interface {
+		switch srcDepth, dstDepth := ptrDepth(srcType), ptrDepth(dstType); {
+		case srcDepth > dstDepth:
+			return newUnwrapConverter(srcType, dstType, options)
+		case srcDepth < dstDepth:
+			return newWrapConverter(srcType, dstType, options)
+		}
+	}
+
 	//options that working in this level shou ld be divided into a new Options
 	switch dstType.Kind() {
 	case reflect.Bool:
@@ -235,12 +441,8 @@ func newConv(srcType, dstType reflect.Type, options *Options) convFunc {
 	case reflect.Struct:
 		return newStructConverter(srcType, dstType, options)
 
-	case reflect.Func:
-		fallthrough
-
-	case reflect.Chan:
-		return UnexpectedTypeConverter
-		//not supported yet
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return newRefConverter(srcType, dstType, options.RefPolicy)
 	default:
 		//not supported type
 		return UnexpectedTypeConverter
@@ -267,14 +469,11 @@ func UnexpectedTypeConverter(c *convState, src reflect.Value, dst reflect.Value,
 func basicConverter(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
 	//
 
-	if src.Kind() == reflect.Ptr && src.IsNil() {
-		fmt.Fprintln(os.Stderr, src.Type())
-		convPanic(&ErrNilSrcPtr{src: src})
-	}
-
-	fmt.Fprintln(os.Stderr, src.Type())
-
 	if !src.Type().AssignableTo(dst.Type()) {
+		if convertible(src.Type(), dst.Type()) {
+			convertValue(src, dst)
+			return
+		}
 		convPanic(&ErrUnableAssignType{src.Type(), dst.Type()})
 	}
 	dst.Set(src)
@@ -297,8 +496,8 @@ func (pc *PtrConverter) conv(c *convState, src reflect.Value, dst reflect.Value,
 
 func newPtrConverter(srcType reflect.Type, dstType reflect.Type, options *Options) convFunc {
 	pc := new(PtrConverter)
-	if options.DeepCopy {
-		pc.elemEnc = newConv(srcType.Elem(), dstType.Elem(), options)
+	if options.DeepCopy || !srcType.AssignableTo(dstType) { // elements of different types are converted
+		pc.elemEnc = cacheConverter(srcType.Elem(), dstType.Elem(), options)
 		return pc.conv
 	} else {
 		return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
@@ -309,19 +508,45 @@ func newPtrConverter(srcType reflect.Type, dstType reflect.Type, options *Option
 }
 
 type field struct {
-	name  string
-	alias string
-	tp    reflect.Type
+	name   string
+	alias  string
+	tp     reflect.Type
+	tagged bool // alias is given by tag
+
+	unexported bool // accessed with unsafe
 
 	hidden      bool
 	ignoreEmpty bool
 
 	param     bool
-	paramName reflect.Value
+	paramName string
+	paramPath []string
 
 	customConv bool
 	converter  reflect.Value
 
+	hasDefault   bool
+	defaultValue reflect.Value
+
+	validation *validation
+
+	getter string // name of src method used as source
+
+	setter     reflect.Method // method of dst used as sink instead of the field
+	hasSetter  bool
+	autoSetter bool // setter found by naming convention, skipped when src has nothing to offer
+
+	from []string // path of aliases to the source in src
+
+	inline bool   // nested dst struct filled from the fields of src itself
+	prefix string // prefix of src aliases of inline struct fields
+
+	nilPolicy    NilPolicy
+	hasNilPolicy bool // nilPolicy overrides the policy of Options
+
+	refPolicy    RefPolicy
+	hasRefPolicy bool // refPolicy overrides the policy of Options
+
 	index []int
 }
 
@@ -349,12 +574,29 @@ func (sf *structField) clone() *structField {
 type pairStructField struct {
 	srcStruct structField
 	dstStruct structField
+	getters   map[string]getter // src methods used as source, indexed by dst alias
+	prefix    string            // prefix of src aliases when dst is filled from flat src fields
+}
+
+// srcIndex returns the index of src field matching dst alias
+func (p *pairStructField) srcIndex(alias string) (int, bool) {
+	return p.srcStruct.sourceIndex(p.prefix + alias)
+}
+
+// sourceIndex looks up a field of alias which can be read from as a source,
+// fields routed to setters only take values
+func (sf structField) sourceIndex(alias string) (int, bool) {
+	index, exist := sf.NameIndex[alias]
+	if exist && sf.List[index].hasSetter {
+		return 0, false
+	}
+	return index, exist
 }
 
 type tagOptions []string
 
 func parseTag(tag string) (name string, opts tagOptions) {
-	res := strings.Split(tag, ",")
+	res := splitTag(tag)
 	if len(res) == 0 {
 		return "", tagOptions{}
 	} else if len(res) == 1 {
@@ -363,36 +605,79 @@ func parseTag(tag string) (name string, opts tagOptions) {
 	return res[0], res[1:]
 }
 
-type structFieldCacheKey struct {
-	t        reflect.Type
-	hashcode uint64
+// splitTag splits tag on commas, except those inside brackets or braces, like
+// in regexp=^[a-z]{1,3}$ or default=["a","b"], and those escaped as \,
+func splitTag(tag string) []string {
+	var res []string
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(tag); i++ {
+		switch ch := tag[i]; {
+		case ch == '\\' && i+1 < len(tag):
+			i++
+			if tag[i] != ',' {
+				b.WriteByte(ch)
+			}
+			b.WriteByte(tag[i])
+			continue
+		case ch == '[' || ch == '{':
+			depth++
+		case (ch == ']' || ch == '}') && depth > 0:
+			depth--
+		case ch == ',' && depth == 0:
+			res = append(res, b.String())
+			b.Reset()
+			continue
+		}
+		b.WriteByte(tag[i])
+	}
+	return append(res, b.String())
 }
 
-var structFieldCache sync.Map
+func tagHasOption(tag string, option string) bool {
+	_, opts := parseTag(tag)
+	for _, opt := range opts {
+		if key, _ := parseTagOption(opt); key == option {
+			return true
+		}
+	}
+	return false
+}
 
-func genStructFieldCacheKey(t reflect.Type, options *Options) structFieldCacheKey {
-	if options.hash() == 6541746513145540259 {
-		fmt.Fprint(os.Stderr, "---------------------", options.LocalRules[0])
+// parseTagOption splits an option like "default=1" into its key and value
+func parseTagOption(opt string) (key string, value string) {
+	if i := strings.Index(opt, "="); i >= 0 {
+		return opt[:i], opt[i+1:]
 	}
+	return opt, ""
+}
+
+type structFieldCacheKey struct {
+	t       reflect.Type
+	options string
+}
 
+func genStructFieldCacheKey(t reflect.Type, options *Options) structFieldCacheKey {
 	return structFieldCacheKey{
-		t:        t,
-		hashcode: options.hash(),
+		t:       t,
+		options: options.key(),
 	}
 }
 
 func cachedStructField(t reflect.Type, options *Options) structField {
 	key := genStructFieldCacheKey(t, options)
+	tracer := options.tracer()
 	if f, ok := structFieldCache.Load(key); ok {
+		trace(tracer, Event{Kind: EventCacheHit, DstType: t, Cache: CacheStructField})
 		return f.(structField)
 	}
+	trace(tracer, Event{Kind: EventCacheMiss, DstType: t, Cache: CacheStructField})
 
 	// change to cache
-	_ret := extractStructFieldFields(t, nil)
+	_ret := extractStructFieldFields(t, options)
 	ret := _ret.clone()
 
 	//fmt.Fprintln(os.Stderr,"->>",ret)
-	fmt.Fprintln(os.Stderr, "->>", t, options)
 	if options != nil {
 		opt := options.effect()
 		for _, grp := range opt.LocalRules {
@@ -423,12 +708,42 @@ func cachedStructField(t reflect.Type, options *Options) structField {
 						if !ok {
 							convPanicStr("localRule: cant find field")
 						}
-						if param == "" {
-							f.param = true
+						f.param = param != ""
+						f.paramName = param
+						f.paramPath = splitParamPath(param)
+					case "default":
+						if v == nil {
+							f.hasDefault = false
+							f.defaultValue = reflect.Value{}
 						} else {
-							f.param = false
+							f.hasDefault = true
+							f.defaultValue = defaultValueOf(f.tp, v)
+						}
+					case "from":
+						from, ok := v.(string)
+						if !ok {
+							convPanicStr("localRule: from is not string")
+						}
+						f.from = splitSrcPath(from)
+					case "prefix":
+						prefix, ok := v.(string)
+						if !ok {
+							convPanicStr("localRule: prefix is not string")
+						}
+						f.inline = true
+						f.prefix = prefix
+					case "nil":
+						f.nilPolicy = nilPolicyOf(v)
+						f.hasNilPolicy = true
+					case "ref":
+						f.refPolicy = refPolicyOf(v)
+						f.hasRefPolicy = true
+					default:
+						if isValidationRule(k) {
+							// copy on write, validation of the tag is shared by cached fields
+							f.validation = f.validation.clone()
+							f.validation.setRule(k, v)
 						}
-						f.paramName = reflect.ValueOf(param)
 					}
 				}
 			}
@@ -442,24 +757,39 @@ func cachedStructField(t reflect.Type, options *Options) structField {
 
 func extractStructFieldFields(t reflect.Type, options *Options) structField {
 	//extract fields and anonymous struct fields(they are treated as same level)
-	//at the time,any conv on unexported field is not supported
+	//anonymous pointers to struct are flattened as well
+	//unexported fields are extracted only with setter or when options.Unexported is set
+
+	unexported := options != nil && options.Unexported
 
 	current := []field{}
 	nxt := []field{{tp: t}}
+	visited := map[reflect.Type]bool{} // embedded types seen at a shallower level
+	// types embedded more than once at a level are walked once, their fields are
+	// recorded twice to be seen as ambiguous, as encoding/json does
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{t: 1}
 
 	fields := []field{}
 	for len(nxt) > 0 {
 		current, nxt = nxt, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
 		for _, now := range current {
+			if visited[now.tp] {
+				continue
+			}
+			visited[now.tp] = true
 			for i := 0; i < now.tp.NumField(); i++ {
 
 				ts := now.tp.Field(i)
 				tft := ts.Type
 				isUnexported := ts.PkgPath != ""
+				embedded := ts.Anonymous && embeddedStructType(tft) != nil
 
 				//fmt.Fprintln(os.Stderr, "->>",ts.Name,ts.Anonymous)
 				if isUnexported {
-					if !(ts.Anonymous && tft.Kind() == reflect.Struct) {
+					// unexported fields can only be set through setter
+					if !embedded && !unexported && !tagHasOption(ts.Tag.Get("conv"), "setter") {
 						continue
 					}
 				}
@@ -467,7 +797,7 @@ func extractStructFieldFields(t reflect.Type, options *Options) structField {
 				index := make([]int, len(now.index)+1)
 				copy(index, now.index)
 				index[len(now.index)] = i
-				if !(tft.Kind() == reflect.Struct && ts.Anonymous) {
+				if !embedded {
 					tag := ts.Tag.Get("conv")
 
 					var ignoreEmpty bool
@@ -476,30 +806,51 @@ func extractStructFieldFields(t reflect.Type, options *Options) structField {
 					var hidden bool
 					var customConv bool
 					var method reflect.Value
+					var hasDefault bool
+					var defaultValue reflect.Value
+					var vd *validation
+					var getterName string
+					var setterName string
+					var from string
+					var inline bool
+					var prefix string
+					var nilPolicy NilPolicy
+					var hasNilPolicy bool
+					var refPolicy RefPolicy
+					var hasRefPolicy bool
 
 					alias, opts := parseTag(tag)
+					tagged := alias != "" && alias != "-"
 
 					if alias == "" {
 						alias = ts.Name
+						if isUnexported && tagHasOption(tag, "setter") {
+							alias = exportedName(ts.Name)
+						}
 					} else if alias == "-" {
 						alias = ""
 						hidden = true
 					}
 
 					if len(opts) > 0 {
+						flags := opts
 						switch opts[0] {
 						case "param":
-							if len(opts) > 2 {
-								convPanicStr("param: too many arguments")
-							}
 							param = true
-							paramName = opts[1]
+							paramName = alias
+							flags = opts[1:]
+							if len(opts) >= 2 {
+								paramName = opts[1]
+								flags = opts[2:]
+							}
 						case "func":
 							customConv = true
 
 							funcName := alias
+							flags = opts[1:]
 							if len(opts) >= 2 {
 								funcName = opts[1]
+								flags = opts[2:]
 							}
 
 							m, exist := reflect.PtrTo(now.tp).MethodByName(funcName)
@@ -521,46 +872,113 @@ func extractStructFieldFields(t reflect.Type, options *Options) structField {
 							//}
 
 							method = m.Func
+						}
 
-						default:
-							for _, opt := range opts {
-								switch opt {
-								case "ignoreEmpty":
-									ignoreEmpty = true
+						for _, opt := range flags {
+							key, value := parseTagOption(opt)
+							switch key {
+							case "ignoreEmpty":
+								ignoreEmpty = true
+							case "default":
+								hasDefault = true
+								defaultValue = parseDefault(ts.Type, value)
+							case "getter":
+								getterName = value
+								if getterName == "" {
+									getterName = exportedName(alias)
 								}
+							case "from":
+								from = value
+							case "inline":
+								inline = true
+							case "prefix":
+								inline = true
+								prefix = value
+							case "nil":
+								nilPolicy = nilPolicyOf(value)
+								hasNilPolicy = true
+							case "ref":
+								refPolicy = refPolicyOf(value)
+								hasRefPolicy = true
+							case "setter":
+								setterName = value
+								if setterName == "" {
+									setterName = "Set" + exportedName(ts.Name)
+								}
+							default:
+								if !isValidationRule(key) {
+									convPanicStr(fmt.Sprintf("unknown tag option %q", opt))
+								}
+								if vd == nil {
+									vd = new(validation)
+								}
+								vd.setRule(key, value)
 							}
 						}
 					}
 
 					f := field{
-						name:  ts.Name,
-						alias: alias,
-						tp:    ts.Type,
+						name:       ts.Name,
+						alias:      alias,
+						tp:         ts.Type,
+						tagged:     tagged,
+						unexported: isUnexported,
 
 						ignoreEmpty: ignoreEmpty,
 						hidden:      hidden,
 
 						param:     param,
-						paramName: reflect.ValueOf(paramName),
+						paramName: paramName,
+						paramPath: splitParamPath(paramName),
 
 						customConv: customConv,
 						converter:  method,
 
+						hasDefault:   hasDefault,
+						defaultValue: defaultValue,
+
+						validation: vd,
+
+						getter: getterName,
+
+						from: splitSrcPath(from),
+
+						inline: inline,
+						prefix: prefix,
+
+						nilPolicy:    nilPolicy,
+						hasNilPolicy: hasNilPolicy,
+
+						refPolicy:    refPolicy,
+						hasRefPolicy: hasRefPolicy,
+
 						index: index,
 					}
+					if setterName != "" {
+						f.setter = findSetter(t, setterName)
+						f.hasSetter = true
+						f.tp = f.setter.Type.In(1)
+					}
 					fields = append(fields, f)
+					if count[now.tp] > 1 && !f.hidden {
+						fields = append(fields, f)
+					}
 					continue
 				}
-				nxt = append(nxt, field{name: ts.Name, index: index, tp: tft})
+				et := embeddedStructType(tft)
+				nextCount[et]++
+				if nextCount[et] == 1 {
+					nxt = append(nxt, field{name: ts.Name, index: index, tp: et})
+				}
 			}
 		}
 	}
 
-	sort.Slice(fields, func(i, j int) bool {
-		if fields[i].hidden != fields[j].hidden {
-			return !fields[i].hidden // fields[i].hidden<fields[j].hidden
-		}
-		return true
+	fields = dominantFields(fields)
+	fields = append(fields, autoSetterFields(t, fields)...)
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return !fields[i].hidden && fields[j].hidden // fields[i].hidden<fields[j].hidden
 	})
 
 	//converter of returned field is empty
@@ -568,6 +986,9 @@ func extractStructFieldFields(t reflect.Type, options *Options) structField {
 	nameIndex := make(map[string]int, len(fields))
 	for i := range fields {
 		f := &fields[i]
+		if f.hidden { // hidden fields are never matched by alias
+			continue
+		}
 
 		//fmt.Fprintln(os.Stderr, "->>", t, f.name, f.tp)
 
@@ -581,14 +1002,81 @@ func extractStructFieldFields(t reflect.Type, options *Options) structField {
 	return structField{List: fields, NameIndex: nameIndex}
 }
 
-func extractPairStructFieldFields(srcType reflect.Type, dstType reflect.Type, options *Options) (p pairStructField) {
+// dominantFields resolves fields sharing an alias with the rules of encoding/json:
+// the shallowest field wins, a tagged field wins among fields of the same depth,
+// and fields left ambiguous are all dropped. Hidden fields are kept as they are
+func dominantFields(fields []field) []field {
+	byAlias := make(map[string][]int)
+	for i := range fields {
+		if fields[i].hidden {
+			continue
+		}
+		byAlias[fields[i].alias] = append(byAlias[fields[i].alias], i)
+	}
+
+	drop := make(map[int]bool)
+	for _, group := range byAlias {
+		if len(group) == 1 {
+			continue
+		}
+		depth := len(fields[group[0]].index)
+		for _, i := range group {
+			if len(fields[i].index) < depth {
+				depth = len(fields[i].index)
+			}
+		}
+		dominant := -1
+		tagged := 0
+		shallowest := 0
+		for _, i := range group {
+			if len(fields[i].index) != depth {
+				continue
+			}
+			shallowest++
+			if fields[i].tagged {
+				tagged++
+				dominant = i
+			}
+		}
+		if shallowest == 1 {
+			for _, i := range group {
+				if len(fields[i].index) == depth {
+					dominant = i
+				}
+			}
+		} else if tagged != 1 {
+			dominant = -1
+		}
+		for _, i := range group {
+			if i != dominant {
+				drop[i] = true
+			}
+		}
+	}
+
+	ret := make([]field, 0, len(fields))
+	for i, f := range fields {
+		if !drop[i] {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+func extractPairStructFieldFields(srcType reflect.Type, dstType reflect.Type, options *Options, prefix string) (p pairStructField) {
 	if srcType.Kind() != reflect.Struct {
 		convPanic(ErrDstStructSrcNotStruct)
 	}
 
 	// options should be divided
-	p.srcStruct = cachedStructField(srcType, nil) //localRules can only be set on dst fields
+	var srcOptions *Options //localRules can only be set on dst fields
+	if options != nil && options.Unexported {
+		srcOptions = new(Options).SetUnexported(true)
+	}
+	p.srcStruct = cachedStructField(srcType, srcOptions)
 	p.dstStruct = cachedStructField(dstType, options)
+	p.getters = make(map[string]getter)
+	p.prefix = prefix
 	//fmt.Fprintln(os.Stderr,p.dstStruct)
 	// cache the field later
 	for i := 0; i < len(p.dstStruct.List); i++ {
@@ -602,9 +1090,42 @@ func extractPairStructFieldFields(srcType reflect.Type, dstType reflect.Type, op
 			continue
 		}
 
-		index, exist := p.srcStruct.NameIndex[f.alias]
+		if len(f.from) > 0 {
+			checkSrcPath(srcType, f.from)
+			continue
+		}
+
+		if f.inline { // checked when the inline converter is built
+			if inlineStructType(f.tp) == nil {
+				convPanicStr(fmt.Sprintf("inline field %s is not struct", f.name))
+			}
+			continue
+		}
+
+		if f.getter != "" {
+			g, exist, err := findGetter(srcType, f.getter)
+			if !exist {
+				convPanicStr(fmt.Sprintf("getter %s not exists", f.getter))
+			}
+			if err != nil {
+				convPanic(err)
+			}
+			p.getters[f.alias] = g
+			continue
+		}
+
+		index, exist := p.srcIndex(f.alias)
 		if !exist {
-			convPanicStr(fmt.Sprintf("field %s not exists", f.alias))
+			if g, ok := autoGetter(srcType, f); ok {
+				p.getters[f.alias] = g
+				continue
+			}
+		}
+		if !exist && (f.hasDefault || f.autoSetter) {
+			continue
+		}
+		if !exist {
+			convPanicStr(fmt.Sprintf("field %s not exists", p.prefix+f.alias))
 		}
 		if p.srcStruct.List[index].hidden {
 			convPanicStr("src field is hidden")
@@ -620,65 +1141,168 @@ func extractPairStructFieldFields(srcType reflect.Type, dstType reflect.Type, op
 
 type structConverter struct {
 	pairStructField
-	options map[string]*Options
+	unexported bool
+	options    map[string]*Options
+	inlines    map[string]convFunc // converters of inline fields, indexed by dst alias
+	children   []convFunc          // converters of fields whose src type is known, indexed like dstStruct.List
+	hooks      structHooks
+	nilPolicy  NilPolicy
 }
 
-var ConverterCache sync.Map
+// building holds the converters whose plans are being built, outside of the
+// cache so that eviction can not drop them before recursive types reach them again
+var building sync.Map
+
+type pairKey struct {
+	srcType    reflect.Type
+	dstType    reflect.Type
+	optionCode string
+}
 
 func cacheConverter(srcType reflect.Type, dstType reflect.Type, options *Options) convFunc {
-	type pairKey struct {
-		srcType    reflect.Type
-		dstType    reflect.Type
-		optionCode uint64
-	}
+	return tracedConverter(srcType, dstType, options, options.tracer())
+}
+
+// tracedConverter is cacheConverter for conversions looking up a plan, which
+// trace to the Tracer of their call rather than to that of the plan holding options
+func tracedConverter(srcType reflect.Type, dstType reflect.Type, options *Options, tracer Tracer) convFunc {
 	key := pairKey{
 		srcType:    srcType,
 		dstType:    dstType,
-		optionCode: options.hash(),
+		optionCode: options.key(),
 	}
 	//fmt.Fprint(os.Stderr, srcType, " ", srcType.PkgPath(), "->", options, "<-", key.optionCode)
 
-	//key,err:=hashstructure.Hash(pairKey{
-	//	srcType:    srcType,
-	//	dstType:    dstType,
-	//	optionCode: options.hash(),
-	//},hashstructure.FormatV2,nil)
-	//
-	//if err!=nil{
-	//	panic(err)
-	//}
-
-	if v, ok := ConverterCache.Load(key); ok {
+	if v, ok := converterCache.Load(key); ok {
 		//fmt.Fprint(os.Stderr,srcType, " ",srcType.PkgPath(),")",v)
+		trace(tracer, Event{Kind: EventCacheHit, SrcType: srcType, DstType: dstType, Cache: CacheConverter})
+		return v.(convFunc)
+	}
+	trace(tracer, Event{Kind: EventCacheMiss, SrcType: srcType, DstType: dstType, Cache: CacheConverter})
+
+	// recursive types reach here again while their converter is being built,
+	// they get an indirect converter waiting for the real one
+	var wg sync.WaitGroup
+	var f convFunc
+	wg.Add(1)
+	v, loaded := building.LoadOrStore(key, convFunc(func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
+		wg.Wait()
+		f(c, src, dst, list)
+	}))
+	if loaded {
 		return v.(convFunc)
 	}
-	v, _ := ConverterCache.LoadOrStore(key, newConv(srcType, dstType, options))
-	return v.(convFunc)
+	defer func() {
+		if r := recover(); r != nil { // plans with error are not cached
+			f = func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
+				panic(r)
+			}
+			wg.Done()
+			building.Delete(key)
+			panic(r)
+		}
+	}()
+
+	f = newConv(srcType, dstType, options.withTracer(tracer))
+	wg.Done()
+	converterCache.Store(key, f)
+	building.Delete(key)
+	trace(tracer, Event{Kind: EventPlanBuilt, SrcType: srcType, DstType: dstType})
+	return f
 }
 
 func newStructConverter(srcType reflect.Type, dstType reflect.Type, options *Options) convFunc {
-	pair := extractPairStructFieldFields(srcType, dstType, options)
+	return newPrefixStructConverter(srcType, dstType, options, "")
+}
+
+// newPrefixStructConverter builds a struct converter whose dst aliases match src aliases with prefix
+func newPrefixStructConverter(srcType reflect.Type, dstType reflect.Type, options *Options, prefix string) convFunc {
+	pair := extractPairStructFieldFields(srcType, dstType, options, prefix)
 	//fmt.Fprintln(os.Stderr,pair)
 
-	sc := structConverter{pairStructField: pair, options: make(map[string]*Options)}
+	sc := structConverter{
+		pairStructField: pair,
+		options:         make(map[string]*Options),
+		inlines:         make(map[string]convFunc),
+		hooks:           newStructHooks(srcType, dstType),
+	}
+	if options != nil {
+		sc.nilPolicy = options.NilPolicy
+		sc.unexported = options.Unexported
+	}
 	//fmt.Fprintln(os.Stderr,sc.pairStructField)
 	for i := 0; i < len(pair.dstStruct.List); i++ { // better way to do it ?
 		df := &pair.dstStruct.List[i]
-		if df.tp.Kind() != reflect.Struct {
-			continue
-		}
 		_, exists := sc.options[df.alias]
 		if exists { //TODO
 			continue
 		}
 
-		sc.options[df.alias] = options.split(df.alias).redirect(df.alias)
+		sc.options[df.alias] = options.split(df.alias)
+		if df.hasRefPolicy { // it applies to references inside the field as well
+			sc.options[df.alias] = sc.options[df.alias].SetRefPolicy(df.refPolicy).Freeze()
+		}
+
+	}
 
+	sc.children = make([]convFunc, len(pair.dstStruct.List))
+	for i := 0; i < len(pair.dstStruct.List); i++ {
+		df := &pair.dstStruct.List[i]
+		if df.hidden || df.customConv || df.param {
+			continue
+		}
+		func() {
+			defer tracePlan(dstType, df)
+			if df.inline {
+				sc.inlines[df.alias] = newPrefixStructConverter(srcType, inlineStructType(df.tp), sc.options[df.alias], prefix+df.prefix)
+				return
+			}
+			if st := sc.fieldSrcType(srcType, df); st != nil {
+				sc.children[i] = cacheConverter(st, df.tp, sc.options[df.alias])
+			}
+		}()
 	}
 
 	return sc.conv
 }
 
+// fieldSrcType returns the type of the source of df, or nil if it is only known at conversion
+func (s *pairStructField) fieldSrcType(srcType reflect.Type, df *field) reflect.Type {
+	if len(df.from) > 0 {
+		return checkSrcPath(srcType, df.from)
+	}
+	if g, ok := s.getters[df.alias]; ok {
+		return g.method.Type.Out(0)
+	}
+	if index, exist := s.srcIndex(df.alias); exist {
+		return s.srcStruct.List[index].tp
+	}
+	return nil
+}
+
+// tracePlan adds the path of df to errors raised while planning its converter
+func tracePlan(dstType reflect.Type, df *field) {
+	if r := recover(); r != nil {
+		if convErr, ok := r.(ConvErr); ok {
+			panic(traceErr(convErr, dstType, df))
+		}
+		panic(r)
+	}
+}
+
+// traceErr prefixes the path of convErr with field df of dstType
+func traceErr(convErr ConvErr, dstType reflect.Type, df *field) ConvErr {
+	newPath := fmt.Sprintf("(%s)", dstType) + df.name
+	dot := ""
+	if convErr.Path != "" {
+		dot = "."
+	} else {
+		convErr.Path = ": "
+	}
+	convErr.Path = newPath + dot + convErr.Path
+	return convErr
+}
+
 var errorInterfaceType = reflect.TypeOf((*error)(nil)).Elem()
 
 func (s *structConverter) conv(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
@@ -689,14 +1313,7 @@ func (s *structConverter) conv(c *convState, src reflect.Value, dst reflect.Valu
 		if r := recover(); r != nil {
 			if convErr, ok := r.(ConvErr); ok {
 				if df != nil {
-					newPath := fmt.Sprintf("(%s)", dst.Type()) + df.name
-					dot := ""
-					if convErr.Path != "" {
-						dot = "."
-					} else {
-						convErr.Path = ": "
-					}
-					convErr.Path = newPath + dot + convErr.Path
+					convErr = traceErr(convErr, dst.Type(), df)
 				}
 				panic(convErr)
 			} else {
@@ -705,73 +1322,220 @@ func (s *structConverter) conv(c *convState, src reflect.Value, dst reflect.Valu
 		}
 	}()
 
+	if s.unexported && !src.CanAddr() { // unexported fields are read through their address
+		src = addressOf(src).Elem()
+	}
+
+	s.hooks.before(src, dst, list)
+
 	for i := 0; i < len(s.dstStruct.List); i++ {
 		df = &s.dstStruct.List[i]
 		if df.hidden {
 			break
 		}
 
-		// custom convertor
-		if df.customConv {
-			var in []reflect.Value
-			in = append(in, dst.Addr(), src, list)
-			ret := df.converter.Call(in)
-
-			firstRet := -1
-			for i, v := range ret {
-				if v.Type() == errorInterfaceType { // panic non-nil error
-					if !v.IsNil() {
-						convPanic(v.Interface().(error))
-					}
-					continue
-				}
-				if firstRet == -1 {
-					firstRet = i
-				}
+		var dv reflect.Value
+		pending := df.hasSetter // dv is a temporary value written back after conversion
+		if !pending {
+			var ok bool
+			dv, ok = fieldByIndex(dst, df.index)
+			pending = !ok // embedded pointer is allocated only if the field is written
+			if ok && df.unexported {
+				dv = exposeField(dv)
 			}
-			if firstRet != -1 {
-				dst.Set(ret[firstRet])
+		}
+		if pending {
+			dv = reflect.New(df.tp).Elem()
+		}
+
+		written := s.convField(c, df, s.children[i], src, dst, dv, list)
+		if written {
+			trace(c.tracer, Event{Kind: EventFieldConverted, SrcType: src.Type(), DstType: dst.Type(), Field: df.name})
+		}
+		if written && df.hasSetter {
+			callSetter(df.setter, dst, dv)
+		} else if written && pending {
+			fv := allocFieldByIndex(dst, df.index)
+			if df.unexported {
+				fv = exposeField(fv)
 			}
-			continue
+			fv.Set(dv)
 		}
 
-		//param convertor
-		if df.param {
+		if df.validation != nil && (written || !pending) {
+			df.validation.validate(dv)
+		}
+	}
+
+	df = nil // errors of after hooks belong to the struct itself
+	s.hooks.after(src, dst, list)
+}
+
+// applyNilPolicy handles a dst field whose source is nil, it reports whether dv is written
+func (s *structConverter) applyNilPolicy(df *field, dv reflect.Value, err error) bool {
+	policy := s.nilPolicy
+	if df.hasNilPolicy {
+		policy = df.nilPolicy
+	}
+	switch policy {
+	case NilSkip:
+		return false
+	case NilZero:
+		dv.Set(reflect.Zero(dv.Type()))
+		return true
+	}
+	convPanic(err)
+	return false
+}
 
-			v := list.MapIndex(df.paramName)
-			if df.ignoreEmpty && !v.IsValid() {
+// convField fills dv, the dst field described by df or the argument of its setter.
+// It reports whether dv is written
+func (s *structConverter) convField(c *convState, df *field, child convFunc, src reflect.Value, dst reflect.Value, dv reflect.Value, list reflect.Value) bool {
+	// custom convertor
+	if df.customConv {
+		trace(c.tracer, Event{Kind: EventCustomFunc, SrcType: src.Type(), DstType: dst.Type(), Field: df.name})
+		if c.report != nil {
+			c.record(df, SourceFunc, getFunctionName(df.converter), OutcomeSet)
+		}
+		var in []reflect.Value
+		in = append(in, dst.Addr(), src, list)
+		ret := df.converter.Call(in)
+
+		firstRet := -1
+		for i, v := range ret {
+			if v.Type() == errorInterfaceType { // panic non-nil error
+				if !v.IsNil() {
+					convPanic(v.Interface().(error))
+				}
 				continue
 			}
-
-			dv := dst
-			for _, j := range df.index {
-				dv = dv.Field(j)
+			if firstRet == -1 {
+				firstRet = i
 			}
+		}
+		if firstRet != -1 {
+			dst.Set(ret[firstRet])
+		}
+		return false
+	}
 
-			dv.Set(v)
+	//param convertor
+	if df.param {
+		v := lookupParam(list, df.paramPath)
+		if !v.IsValid() {
+			if df.hasDefault {
+				dv.Set(copyDefault(df.defaultValue))
+				c.record(df, SourceParam, df.paramName, OutcomeDefault)
+				return true
+			}
+			if df.ignoreEmpty {
+				c.record(df, SourceParam, df.paramName, OutcomeSkipped)
+				return false
+			}
+			convPanic(&ErrMissingParam{name: df.paramName})
 		}
 
-		// default convertor
-		dv := dst
-		sIndex := s.srcStruct.NameIndex[df.alias]
+		c.record(df, SourceParam, df.paramName, OutcomeSet)
+		c.enter(df.name, "")
+		tracedConverter(v.Type(), dv.Type(), s.options[df.alias], c.tracer)(c, v, dv, list)
+		c.leave()
+		return true
+	}
+
+	// default convertor
+	var sv reflect.Value
+	var source, from string
+	if len(df.from) > 0 {
+		source = SourcePath
+		if c.report != nil {
+			from = strings.Join(df.from, ".")
+		}
+		var ok bool
+		sv, ok = lookupSrcPath(src, df.from)
+		if !ok {
+			if df.hasDefault {
+				dv.Set(copyDefault(df.defaultValue))
+				c.record(df, source, from, OutcomeDefault)
+				return true
+			}
+			written := s.applyNilPolicy(df, dv, &ErrNilSrcPath{path: strings.Join(df.from, ".")})
+			c.recordNil(df, source, from, written)
+			return written
+		}
+	} else if df.inline {
+		target := dv
+		if target.Kind() == reflect.Ptr {
+			if target.IsNil() {
+				target.Set(reflect.New(target.Type().Elem()))
+			}
+			target = target.Elem()
+		}
+		c.record(df, SourceInline, "", OutcomeSet)
+		c.enter(df.name, "")
+		s.inlines[df.alias](c, src, target, list)
+		c.leave()
+		return true
+	} else if g, ok := s.getters[df.alias]; ok {
+		source, from = SourceGetter, g.method.Name+"()"
+		sv = g.get(src)
+	} else {
+		sIndex, exist := s.srcIndex(df.alias)
+		if !exist { // only fields with default value or found setter can be missing in src
+			if !df.hasDefault {
+				return false
+			}
+			dv.Set(copyDefault(df.defaultValue))
+			c.record(df, SourceDefault, "", OutcomeDefault)
+			return true
+		}
 		//fmt.Println(i," ",sIndex[0])
 		sf := &s.srcStruct.List[sIndex]
-		sv := src
-
-		for _, j := range df.index {
-			dv = dv.Field(j)
+		source, from = SourceField, sf.name
+		var ok bool
+		sv, ok = fieldByIndex(src, sf.index)
+		if !ok { // fields of nil embedded pointer are absent
+			if df.hasDefault {
+				dv.Set(copyDefault(df.defaultValue))
+				c.record(df, source, from, OutcomeDefault)
+				return true
+			}
+			written := s.applyNilPolicy(df, dv, &ErrNilEmbedded{field: sf.name})
+			c.recordNil(df, source, from, written)
+			return written
 		}
-
-		for _, j := range sf.index {
-			sv = sv.Field(j)
+		if sf.unexported {
+			sv = exposeField(sv)
 		}
-		//fmt.Fprintln(os.Stderr,df,dv,sv)
+	}
+	//fmt.Fprintln(os.Stderr,df,dv,sv)
 
-		if df.ignoreEmpty && sv.IsZero() {
-			continue
-		}
-		newConv(sv.Type(), dv.Type(), s.options[df.alias])(c, sv, dv, list)
+	if df.hasDefault && sv.IsZero() {
+		dv.Set(copyDefault(df.defaultValue))
+		c.record(df, source, from, OutcomeDefault)
+		return true
+	}
+	if df.ignoreEmpty && sv.IsZero() {
+		c.record(df, source, from, OutcomeSkipped)
+		return false
+	}
+	emptyNil := s.options[df.alias].EmptyNil && canBeEmpty(sv.Type()) && canBeEmpty(dv.Type())
+	if df.hasNilPolicy && !emptyNil && canBeNil(sv.Type()) && sv.IsNil() { // policy of the field overrides that of options
+		written := applyNilValue(df.nilPolicy, sv, dv)
+		c.recordNil(df, source, from, written)
+		return written
 	}
+	if isRef(dv.Type()) && s.options[df.alias].RefPolicy == RefSkip {
+		c.record(df, source, from, OutcomeSkipped)
+		return false
+	}
+	if child == nil { // type of src is only known now
+		child = tracedConverter(sv.Type(), dv.Type(), s.options[df.alias], c.tracer)
+	}
+	c.record(df, source, from, OutcomeSet)
+	c.enter(df.name, from)
+	child(c, sv, dv, list)
+	c.leave()
+	return true
 }
 
 type mapConverter struct {
@@ -793,14 +1557,14 @@ func newMapConverter(srcType reflect.Type, dstType reflect.Type, options *Option
 	srcElem := srcType.Elem()
 	dstElem := dstType.Elem()
 
-	if !options.DeepCopy {
+	if !options.DeepCopy && srcType.AssignableTo(dstType) {
 		return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
 			dst.Set(src)
 		}
 	}
 	var elemFunc convFunc
 	//if srcType != dstType {
-	elemFunc = newConv(srcElem, dstElem, options)
+	elemFunc = cacheConverter(srcElem, dstElem, options)
 	//}
 
 	mapConv := mapConverter{elemFunc: elemFunc}
@@ -822,7 +1586,9 @@ func (m *mapConverter) conv(c *convState, src reflect.Value, dst reflect.Value,
 			dst.SetMapIndex(k, src.MapIndex(k))
 		} else {
 			tmpValue := reflect.New(dstElem).Elem()
+			c.enterIndex(k.Interface())
 			m.elemFunc(c, src.MapIndex(k), tmpValue, list)
+			c.leave()
 			dst.SetMapIndex(k, tmpValue)
 		}
 	}
@@ -833,12 +1599,12 @@ type sliceConverter struct {
 }
 
 func (s *sliceConverter) conv(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
-	srcElem := src.Type().Elem()
-	elemSlice := reflect.SliceOf(srcElem)
-	dst.Set(reflect.MakeSlice(elemSlice, src.Len(), src.Len()))
+	dst.Set(reflect.MakeSlice(dst.Type(), src.Len(), src.Len()))
 	//fmt.Fprintln(os.Stderr, "->>", src.Len())
 	for i := 0; i < src.Len(); i++ {
+		c.enterIndex(i)
 		s.elemFunc(c, src.Index(i), dst.Index(i), list)
+		c.leave()
 	}
 }
 
@@ -849,12 +1615,12 @@ func newSliceConverter(srcType reflect.Type, dstType reflect.Type, options *Opti
 
 	srcElem := srcType.Elem()
 	dstElem := dstType.Elem()
-	if !options.DeepCopy {
+	if !options.DeepCopy && srcType.AssignableTo(dstType) {
 		return func(c *convState, src reflect.Value, dst reflect.Value, list reflect.Value) {
 			dst.Set(src)
 		}
 	}
-	sliceConv := sliceConverter{elemFunc: newConv(srcElem, dstElem, options)}
+	sliceConv := sliceConverter{elemFunc: cacheConverter(srcElem, dstElem, options)}
 	return sliceConv.conv
 }
 
@@ -867,7 +1633,7 @@ func getFunctionName(i reflect.Value) string {
 // ShowTypeJson return a string represent conversion property of given types and options in json,
 // used for debugging
 func ShowTypeJson(src, dst interface{}, options *Options) string {
-	js := showTypeJson(reflect.TypeOf(src), reflect.TypeOf(dst), options)
+	js := showTypeJson(reflect.TypeOf(src), reflect.TypeOf(dst), options, "")
 	str, err := json.MarshalIndent(js, "", "    ")
 	if err != nil {
 		panic(err)
@@ -875,11 +1641,11 @@ func ShowTypeJson(src, dst interface{}, options *Options) string {
 	return string(str)
 }
 
-func showTypeJson(srcType reflect.Type, dstType reflect.Type, options *Options) typeJson {
+func showTypeJson(srcType reflect.Type, dstType reflect.Type, options *Options, prefix string) typeJson {
 
 	ret := make(typeJson)
 
-	pair := extractPairStructFieldFields(srcType, dstType, options)
+	pair := extractPairStructFieldFields(srcType, dstType, options, prefix)
 
 	for i := 0; i < len(pair.dstStruct.List); i++ {
 		df := &pair.dstStruct.List[i]
@@ -902,32 +1668,58 @@ func showTypeJson(srcType reflect.Type, dstType reflect.Type, options *Options)
 			fm["func"] = getFunctionName(df.converter) + " " + df.converter.Type().String()
 		}
 
-		fm["srcField"] = srcType.Name() + "." + pair.srcStruct.List[pair.srcStruct.NameIndex[df.alias]].name
+		if df.hasDefault {
+			fm["default"] = fmt.Sprintf("%v", df.defaultValue)
+		}
+		if df.hasSetter {
+			fm["setter"] = df.setter.Name
+		}
+		if g, ok := pair.getters[df.alias]; ok {
+			fm["srcField"] = srcType.Name() + "." + g.method.Name + "()"
+			ret[df.name] = fm
+			continue
+		}
+		if len(df.from) > 0 {
+			fm["srcField"] = srcType.Name() + "." + strings.Join(df.from, ".")
+			ret[df.name] = fm
+			continue
+		}
+		if df.inline && !df.customConv && !df.param {
+			fm["prefix"] = prefix + df.prefix
+			if _, exist := ret["Subfield"]; !exist {
+				ret["Subfield"] = make([]typeJson, 0)
+			}
+			ret["Subfield"] = append(ret["Subfield"].([]typeJson),
+				showTypeJson(srcType, inlineStructType(df.tp), options.split(df.alias), prefix+df.prefix))
+			ret[df.name] = fm
+			continue
+		}
+		sIndex, exist := pair.srcIndex(df.alias)
+		if df.param || !exist {
+			ret[df.name] = fm
+			continue
+		}
+
+		fm["srcField"] = srcType.Name() + "." + pair.srcStruct.List[sIndex].name
 		if df.tp.Kind() == reflect.Struct && !df.customConv && !df.param {
 
 			dt := dstType
-			sIndex := pair.srcStruct.NameIndex[df.alias]
 			//fmt.Println(i," ",sIndex[0])
 			sf := &pair.srcStruct.List[sIndex]
 			st := srcType
 
-			for _, j := range df.index {
-				dt = dt.Field(j).Type
-			}
-
-			for _, j := range sf.index {
-				st = st.Field(j).Type
-			}
+			dt = typeByIndex(dt, df.index)
+			st = typeByIndex(st, sf.index)
 
 			if _, exist := ret["Subfield"]; !exist {
 				ret["Subfield"] = make([]typeJson, 0)
 			}
 			ret["Subfield"] = append(ret["Subfield"].([]typeJson),
-				showTypeJson(st, dt, options.split(df.alias).redirect(df.alias)))
+				showTypeJson(st, dt, options.split(df.alias), ""))
 		}
 		ret[df.name] = fm
 	}
 	return typeJson{
-		dstType.Name() + fmt.Sprintf(" (hash:%d)", options.hash()): ret,
+		dstType.Name() + fmt.Sprintf(" (options:%s)", options.key()): ret,
 	}
 }
@@ -1,12 +1,18 @@
 package ssconv
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/go-cmp/cmp"
+	"math"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"unsafe"
 )
 
 var debug bool
@@ -641,3 +647,1639 @@ func TestErrorTrace2(t *testing.T) {
 	debugOutput(dst2)
 	debugOutput(expect2)
 }
+
+func TestStructParam(t *testing.T) {
+	type Session struct {
+		Token string
+	}
+	type Resp struct {
+		ID     string `conv:"id"`
+		Token  string `conv:"token,param,session.Token"`
+		Locale string `conv:"locale,param,ctx.locale"`
+		Level  int    `conv:"level,param"`
+	}
+	src := dbUser{ID: "yokel"}
+	var dst Resp
+	list := ParamList{
+		"session": &Session{Token: "abc"},
+		"ctx":     map[string]interface{}{"locale": "en"},
+		"level":   3,
+	}
+	err := Conv(src, &dst, nil, list)
+	if err != nil {
+		t.Error(err)
+	}
+	expect := Resp{ID: "yokel", Token: "abc", Locale: "en", Level: 3}
+	debugOutput(dst)
+	if !cmp.Equal(expect, dst) {
+		t.Error()
+	}
+}
+
+func TestStructParamMissing(t *testing.T) {
+	type Resp struct {
+		ID    string `conv:"id"`
+		Token string `conv:"token,param,session.token"`
+	}
+	var dst Resp
+	err := Conv(dbUser{ID: "yokel"}, &dst, nil, ParamList{"session": ParamList{}})
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: (ssconv.Resp)Token: param session.token is required but missing in list" {
+		t.Error(err)
+	}
+
+	type Resp2 struct {
+		ID    string `conv:"id"`
+		Token string `conv:"token,param,session.token"`
+	}
+	var dst2 Resp2
+	dst2.Token = "keep"
+	err = Conv(dbUser{ID: "yokel"}, &dst2, new(Options).AddLocalRule(
+		NewLocalRuleGroup("").AddRule(
+			"token",
+			map[string]interface{}{
+				"ignoreEmpty": true,
+			})),
+		*new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	if dst2.Token != "keep" || dst2.ID != "yokel" {
+		t.Error()
+	}
+}
+
+func TestStructDefault(t *testing.T) {
+	type Resp struct {
+		ID     string   `conv:"id"`
+		Avatar string   `conv:"avatar,default=default.jpg"`
+		Gender int      `conv:"gender,default=2"`
+		Tags   []string `conv:"tags,default=[\"new\"]"`
+		Level  *int     `conv:"level,param,level,default=1"`
+	}
+	var dst Resp
+	err := Conv(dbUser{ID: "yokel", Gender: 1}, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	level := 1
+	expect := Resp{ID: "yokel", Avatar: "default.jpg", Gender: 1, Tags: []string{"new"}, Level: &level}
+	debugOutput(dst)
+	if !cmp.Equal(expect, dst) {
+		t.Error()
+	}
+
+	var dst1 Resp
+	err = Conv(dbUser{ID: "yokel"}, &dst1, new(Options).AddLocalRule(
+		NewLocalRuleGroup("").AddRule(
+			"avatar",
+			map[string]interface{}{
+				"default": "local.jpg",
+			}).AddRule(
+			"gender",
+			map[string]interface{}{
+				"default": 3,
+			})),
+		*new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	if dst1.Avatar != "local.jpg" || dst1.Gender != 3 {
+		t.Error(dst1)
+	}
+
+	// json defaults keep their commas
+	type Multi struct {
+		Tags  []string       `conv:"tags,default=[\"a\",\"b\"]"`
+		Attrs map[string]int `conv:"attrs,default={\"a\":1,\"b\":2}"`
+	}
+	var dst2 Multi
+	err = Conv(struct{}{}, &dst2, nil, *new(ParamList))
+	if err != nil || !cmp.Equal(Multi{Tags: []string{"a", "b"}, Attrs: map[string]int{"a": 1, "b": 2}}, dst2) {
+		t.Error(err, dst2)
+	}
+
+	// values of rules are converted without loss only
+	for _, c := range []struct {
+		field string
+		value interface{}
+		err   string
+	}{
+		{"avatar", 65, "ssconvError: cant use \"65\" as default value of string: cant not assign int in src to string in dst"},
+		{"gender", 3.7, "ssconvError: cant use \"3.7\" as default value of int: value 3.7 of float64 in src does not fit int in dst"},
+		{"gender", int8(3), ""},
+	} {
+		var dst Resp
+		err = Conv(dbUser{ID: "yokel"}, &dst, new(Options).AddLocalRule(
+			NewLocalRuleGroup("").AddRule(c.field, map[string]interface{}{"default": c.value})), *new(ParamList))
+		if (err == nil && c.err != "") || (err != nil && err.Error() != c.err) {
+			t.Error(c.value, err)
+		}
+	}
+}
+
+func TestStructDefaultInvalid(t *testing.T) {
+	type Resp struct {
+		Gender int `conv:"gender,default=male"`
+	}
+	var dst Resp
+	err := Conv(dbUser{}, &dst, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil {
+		t.Error()
+	}
+}
+
+func TestStructValidation(t *testing.T) {
+	type Resp struct {
+		ID     string `conv:"id,required,min=3,max=8"`
+		Avatar string `conv:"avatar,regexp=^[a-z]+\\.jpg$"`
+		Gender int    `conv:"gender,oneof=0 1 2"`
+		Age    int    `conv:"age,min=18"`
+	}
+	var dst Resp
+	src := dbUser{ID: "yokel", Avatar: "hello.jpg", Gender: 1, Age: 20}
+	err := Conv(src, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+
+	cases := []struct {
+		src    dbUser
+		expect string
+	}{
+		{dbUser{Avatar: "a.jpg", Age: 20}, "ssconvError: (ssconv.Resp)ID: validation required failed: value is empty"},
+		{dbUser{ID: "yo", Avatar: "a.jpg", Age: 20}, "ssconvError: (ssconv.Resp)ID: validation min=3 failed: got 2"},
+		{dbUser{ID: "yokel", Avatar: "a.png", Age: 20}, "ssconvError: (ssconv.Resp)Avatar: validation regexp=^[a-z]+\\.jpg$ failed: got \"a.png\""},
+		{dbUser{ID: "yokel", Avatar: "a.jpg", Gender: 3, Age: 20}, "ssconvError: (ssconv.Resp)Gender: validation oneof=0 1 2 failed: got \"3\""},
+		{dbUser{ID: "yokel", Avatar: "a.jpg", Age: 17}, "ssconvError: (ssconv.Resp)Age: validation min=18 failed: got 17"},
+	}
+	for _, cs := range cases {
+		var dst Resp
+		err := Conv(cs.src, &dst, nil, *new(ParamList))
+		debugOutput(err)
+		if err == nil || err.Error() != cs.expect {
+			t.Error(err)
+		}
+	}
+
+	var dst1 Resp
+	err = Conv(dbUser{ID: "yokel", Avatar: "a.jpg", Age: 20}, &dst1, new(Options).AddLocalRule(
+		NewLocalRuleGroup("").AddRule(
+			"id",
+			map[string]interface{}{
+				"len": 3,
+			})),
+		*new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: (ssconv.Resp)ID: validation len=3 failed: got 5" {
+		t.Error(err)
+	}
+
+	// commas inside brackets and braces or escaped ones do not end the option
+	type Quantified struct {
+		ID     string `conv:"id,regexp=^[a-z]{1,5}$"`
+		Avatar string `conv:"avatar,regexp=^[a-z]+\\,?[a-z]*\\.jpg$"`
+	}
+	var dst2 Quantified
+	err = Conv(dbUser{ID: "ab", Avatar: "a,b.jpg"}, &dst2, nil, *new(ParamList))
+	if err != nil || dst2.ID != "ab" {
+		t.Error(err, dst2)
+	}
+	err = Conv(dbUser{ID: "abcdef", Avatar: "a.jpg"}, &dst2, nil, *new(ParamList))
+	if err == nil || err.Error() != "ssconvError: (ssconv.Quantified)ID: validation regexp=^[a-z]{1,5}$ failed: got \"abcdef\"" {
+		t.Error(err)
+	}
+
+	// options left by a wrong split are reported instead of ignored
+	type Unknown struct {
+		ID string `conv:"id,regexp=^a{1\\,3}$,3}$"`
+	}
+	err = Conv(dbUser{ID: "a"}, &Unknown{}, nil, *new(ParamList))
+	if err == nil || err.Error() != "ssconvError: unknown tag option \"3}$\"" {
+		t.Error(err)
+	}
+}
+
+type hookName struct {
+	First    string `conv:"first"`
+	Last     string `conv:"last"`
+	FullName string `conv:"-"`
+	Greeting string `conv:"-"`
+	Locale   string `conv:"-"`
+}
+
+func (n *hookName) BeforeConv(src interface{}, list ParamList) error {
+	n.Greeting = "hello"
+	return nil
+}
+
+func (n *hookName) AfterConv(src interface{}, list ParamList) error {
+	if n.Last == "" {
+		return errors.New("last name is empty")
+	}
+	n.FullName = n.First + " " + n.Last
+	n.Locale, _ = list["locale"].(string)
+	return nil
+}
+
+type hookDbName struct {
+	First string `conv:"first"`
+	Last  string `conv:"last"`
+	conv  int
+}
+
+func (n *hookDbName) AfterConvTo(dst interface{}, list ParamList) error {
+	n.conv++
+	return nil
+}
+
+type hookPerson struct {
+	Name hookName `conv:"name"`
+}
+
+type hookDbPerson struct {
+	Name hookDbName `conv:"name"`
+}
+
+func TestStructHook(t *testing.T) {
+	src := hookDbName{First: "yo", Last: "kel"}
+	var dst hookName
+	err := Conv(&src, &dst, nil, ParamList{"locale": "en"})
+	if err != nil {
+		t.Error(err)
+	}
+	expect := hookName{First: "yo", Last: "kel", FullName: "yo kel", Greeting: "hello", Locale: "en"}
+	debugOutput(dst)
+	if !cmp.Equal(expect, dst) || src.conv != 1 {
+		t.Error()
+	}
+
+	var dst1 hookPerson
+	err = Conv(hookDbPerson{Name: hookDbName{First: "yo"}}, &dst1, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: (ssconv.hookPerson)Name: AfterConv of hookName: last name is empty" {
+		t.Error(err)
+	}
+}
+
+type getterDbUser struct {
+	ID    string `conv:"id"`
+	First string
+	Last  string
+	Score int
+}
+
+func (u getterDbUser) FullName() string {
+	return u.First + " " + u.Last
+}
+
+func (u *getterDbUser) Level() (int, error) {
+	if u.Score < 0 {
+		return 0, errors.New("negative score")
+	}
+	return u.Score / 10, nil
+}
+
+func TestStructGetter(t *testing.T) {
+	type Resp struct {
+		ID       string `conv:"id"`
+		FullName string `conv:"fullName"`
+		Rank     int    `conv:"rank,getter=Level"`
+	}
+	var dst Resp
+	err := Conv(getterDbUser{ID: "yokel", First: "yo", Last: "kel", Score: 42}, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	expect := Resp{ID: "yokel", FullName: "yo kel", Rank: 4}
+	debugOutput(dst)
+	if !cmp.Equal(expect, dst) {
+		t.Error()
+	}
+
+	var dst1 Resp
+	err = Conv(&getterDbUser{Score: -1}, &dst1, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: (ssconv.Resp)Rank: negative score" {
+		t.Error(err)
+	}
+
+	type Resp2 struct {
+		Name string `conv:"name,getter=First"`
+	}
+	var dst2 Resp2
+	err = Conv(getterDbUser{}, &dst2, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: getter First not exists" {
+		t.Error(err)
+	}
+}
+
+type setterUser struct {
+	ID     string `conv:"id"`
+	avatar string `conv:"avatar,setter"`
+	gender int
+	age    int
+}
+
+func (u *setterUser) SetAvatar(avatar string) {
+	u.avatar = "cdn/" + avatar
+}
+
+func (u *setterUser) SetGender(gender int) error {
+	if gender < 0 || gender > 2 {
+		return errors.New("invalid gender")
+	}
+	u.gender = gender
+	return nil
+}
+
+func (u *setterUser) SetAge(age int) {
+	u.age = age
+}
+
+type setterDbUser struct {
+	ID     string `conv:"id"`
+	Avatar string `conv:"avatar"`
+	Gender int
+}
+
+func TestStructSetter(t *testing.T) {
+	var dst setterUser
+	err := Conv(setterDbUser{ID: "yokel", Avatar: "hello.jpg", Gender: 1}, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	debugOutput(dst)
+	if dst.ID != "yokel" || dst.avatar != "cdn/hello.jpg" || dst.gender != 1 || dst.age != 0 {
+		t.Error()
+	}
+
+	var dst1 setterUser
+	err = Conv(setterDbUser{ID: "yokel", Gender: 3}, &dst1, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: (ssconv.setterUser)SetGender: invalid gender" {
+		t.Error(err)
+	}
+
+	// setters of src are not sources, its getters are
+	var dst2 struct {
+		Name string
+	}
+	err = Conv(&encapsulated{name: "yokel"}, &dst2, nil, *new(ParamList))
+	if err != nil || dst2.Name != "yokel" {
+		t.Error(err, dst2)
+	}
+}
+
+type encapsulated struct {
+	name string
+}
+
+func (e *encapsulated) Name() string {
+	return e.name
+}
+
+func (e *encapsulated) SetName(name string) {
+	e.name = name
+}
+
+func TestStructFrom(t *testing.T) {
+	type Profile struct {
+		Avatar string `conv:"avatar"`
+	}
+	type Account struct {
+		Profile *Profile               `conv:"profile"`
+		Extra   map[string]interface{} `conv:"extra"`
+	}
+	type Src struct {
+		ID   string   `conv:"id"`
+		User *Account `conv:"user"`
+	}
+	type Resp struct {
+		ID     string `conv:"id"`
+		Avatar string `conv:"avatar,from=user.profile.avatar"`
+		Locale string `conv:"locale,from=user.extra.locale,nil=skip"`
+	}
+	var dst Resp
+	src := Src{ID: "yokel", User: &Account{
+		Profile: &Profile{Avatar: "hello.jpg"},
+		Extra:   map[string]interface{}{"locale": "en"},
+	}}
+	err := Conv(src, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	expect := Resp{ID: "yokel", Avatar: "hello.jpg", Locale: "en"}
+	debugOutput(dst)
+	if !cmp.Equal(expect, dst) {
+		t.Error()
+	}
+
+	var dst1 Resp
+	err = Conv(Src{ID: "yokel"}, &dst1, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: (ssconv.Resp)Avatar: value on the way of user.profile.avatar in src is nil" {
+		t.Error(err)
+	}
+
+	dst2 := Resp{Avatar: "old.jpg", Locale: "zh"}
+	err = Conv(Src{ID: "yokel"}, &dst2, new(Options).SetNilPolicy(NilZero), *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	expect2 := Resp{ID: "yokel", Avatar: "", Locale: "zh"}
+	debugOutput(dst2)
+	if !cmp.Equal(expect2, dst2) {
+		t.Error()
+	}
+
+	type BadResp struct {
+		Avatar string `conv:"avatar,from=user.name"`
+	}
+	var dst3 BadResp
+	err = Conv(src, &dst3, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: from user.name: field name not exists" {
+		t.Error(err)
+	}
+}
+
+func TestStructInline(t *testing.T) {
+	type Src struct {
+		ID         string
+		AddrStreet string
+		AddrCity   string
+		AddrGeoLat float64
+		AddrGeoLng float64
+		Phone      string
+	}
+	type Geo struct {
+		Lat float64
+		Lng float64
+	}
+	type Address struct {
+		Street string
+		City   string
+		Geo    *Geo `conv:",prefix=Geo"`
+	}
+	type Contact struct {
+		Phone string
+	}
+	type Resp struct {
+		ID      string
+		Address Address `conv:",prefix=Addr"`
+		Contact Contact `conv:",inline"`
+	}
+	src := Src{ID: "yokel", AddrStreet: "Main St", AddrCity: "Town", AddrGeoLat: 1.5, AddrGeoLng: 2.5, Phone: "123"}
+	var dst Resp
+	err := Conv(src, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	expect := Resp{
+		ID:      "yokel",
+		Address: Address{Street: "Main St", City: "Town", Geo: &Geo{Lat: 1.5, Lng: 2.5}},
+		Contact: Contact{Phone: "123"},
+	}
+	debugOutput(dst)
+	if !cmp.Equal(expect, dst) {
+		t.Error()
+	}
+	debugOutput(ShowTypeJson(src, dst, nil))
+	plan, err := Explain(src, &dst, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	address := plan.Fields[1].Plan
+	if plan.Fields[1].Source != SourceInline || address.Converter != ConverterInline ||
+		address.Fields[2].Plan.Fields[0].SrcField != "AddrGeoLat" {
+		t.Error(address)
+	}
+
+	type BadResp struct {
+		Address Address `conv:",prefix=Home"`
+	}
+	var dst1 BadResp
+	err = Conv(src, &dst1, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: (ssconv.BadResp)Address: field HomeStreet not exists" {
+		t.Error(err)
+	}
+	_, err = Explain(src, &dst1, nil)
+	if err == nil || err.Error() != "ssconvError: (ssconv.BadResp)Address: field HomeStreet not exists" {
+		t.Error(err)
+	}
+}
+
+func TestStructEmbeddedConflict(t *testing.T) {
+	type Base struct {
+		ID      string
+		Created int
+		Name    string
+	}
+	type Audit struct {
+		Created int
+		Updated int
+	}
+	type Named struct {
+		Name string `conv:"Name"`
+	}
+	type Model struct {
+		Base
+		Audit
+		Named
+		ID string // shallower than Base.ID
+	}
+	src := Model{
+		Base:  Base{ID: "base", Created: 1, Name: "base"},
+		Audit: Audit{Created: 2, Updated: 3},
+		Named: Named{Name: "named"},
+		ID:    "model",
+	}
+
+	type Resp struct {
+		ID      string
+		Updated int
+		Name    string
+	}
+	var dst Resp
+	err := Conv(src, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	expect := Resp{ID: "model", Updated: 3, Name: "named"}
+	debugOutput(dst)
+	if !cmp.Equal(expect, dst) {
+		t.Error()
+	}
+
+	type AmbiguousResp struct {
+		Created int
+	}
+	var dst1 AmbiguousResp
+	err = Conv(src, &dst1, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: field Created not exists" {
+		t.Error(err)
+	}
+
+	// the same type embedded twice at a level is ambiguous as well
+	type Inner struct {
+		X int
+	}
+	type Left struct {
+		Inner
+	}
+	type Right struct {
+		Inner
+	}
+	type Twice struct {
+		Left
+		Right
+	}
+	twice := Twice{Left: Left{Inner{X: 1}}, Right: Right{Inner{X: 2}}}
+	if b, _ := json.Marshal(twice); string(b) != "{}" {
+		t.Error(string(b))
+	}
+	var dst2 struct {
+		X int
+	}
+	err = Conv(twice, &dst2, nil, *new(ParamList))
+	if err == nil || err.Error() != "ssconvError: field X not exists" {
+		t.Error(err, dst2)
+	}
+}
+
+func TestStructEmbeddedPtr(t *testing.T) {
+	type Base struct {
+		ID      string
+		Created int
+	}
+	type Src struct {
+		*Base
+		Name string
+	}
+	type DstBase struct {
+		ID      string
+		Created int `conv:",ignoreEmpty"`
+	}
+	type Dst struct {
+		*DstBase
+		Name string
+	}
+	var dst Dst
+	err := Conv(Src{Base: &Base{ID: "yokel", Created: 1}, Name: "yo"}, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	expect := Dst{DstBase: &DstBase{ID: "yokel", Created: 1}, Name: "yo"}
+	debugOutput(dst)
+	if !cmp.Equal(expect, dst) {
+		t.Error()
+	}
+
+	var dst1 Dst
+	err = Conv(Src{Name: "yo"}, &dst1, nil, *new(ParamList))
+	debugOutput(err)
+	if err == nil || err.Error() != "ssconvError: (ssconv.Dst)ID: embedded pointer on the way of ID in src is nil" {
+		t.Error(err)
+	}
+
+	var dst2 Dst
+	err = Conv(Src{Name: "yo"}, &dst2, new(Options).SetNilPolicy(NilSkip), *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	expect2 := Dst{Name: "yo"}
+	debugOutput(dst2)
+	if !cmp.Equal(expect2, dst2) {
+		t.Error()
+	}
+}
+
+type unexportedMoney struct {
+	amount   int64
+	currency string
+	tags     []string
+	Note     string
+}
+
+func TestStructUnexported(t *testing.T) {
+	src := unexportedMoney{amount: 100, currency: "USD", tags: []string{"a"}, Note: "note"}
+
+	var dst unexportedMoney
+	err := Conv(src, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	if dst.amount != 0 || dst.currency != "" || dst.Note != "note" {
+		t.Error(dst)
+	}
+
+	var dst1 unexportedMoney
+	err = Conv(src, &dst1, new(Options).SetUnexported(true).SetDeepCode(true), *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	debugOutput(dst1)
+	if !cmp.Equal(src, dst1, cmp.AllowUnexported(unexportedMoney{})) {
+		t.Error()
+	}
+	dst1.tags[0] = "b"
+	if src.tags[0] != "a" {
+		t.Error()
+	}
+}
+
+type recursiveNode struct {
+	Name     string
+	Children []recursiveNode
+}
+
+func TestRecursiveType(t *testing.T) {
+	src := recursiveNode{
+		Name:     "root",
+		Children: []recursiveNode{{Name: "a"}, {Name: "b", Children: []recursiveNode{{Name: "c"}}}},
+	}
+	var dst recursiveNode
+	err := Conv(src, &dst, new(Options).SetDeepCode(true), *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(dst.Children) != 2 || len(dst.Children[1].Children) != 1 || dst.Children[1].Children[0].Name != "c" {
+		debugOutput(dst)
+		t.Error()
+	}
+	if &dst.Children[0] == &src.Children[0] {
+		t.Error("children are not copied")
+	}
+}
+
+type plainPoint struct {
+	X, Y  int
+	Label string
+	Box   struct{ Size [2]float64 }
+}
+
+type plainIgnoreEmpty struct {
+	X     int
+	Label string `conv:",ignoreEmpty"`
+}
+
+func TestPlainType(t *testing.T) {
+	op := new(Options).SetDeepCode(true)
+	if newPlainConverter(reflect.TypeOf(plainPoint{}), op) == nil {
+		t.Error("plainPoint should be copied as a whole")
+	}
+	if newPlainConverter(reflect.TypeOf([]plainPoint{}), op) == nil {
+		t.Error("[]plainPoint should be copied as a whole")
+	}
+	if newPlainConverter(reflect.TypeOf(plainIgnoreEmpty{}), op) != nil {
+		t.Error("plainIgnoreEmpty should be converted field by field")
+	}
+	if newPlainConverter(reflect.TypeOf(recursiveNode{}), op) != nil {
+		t.Error("recursiveNode should be converted field by field")
+	}
+
+	src := []plainPoint{{X: 1, Y: 2, Label: "a"}, {X: 3, Label: "b"}}
+	src[1].Box.Size = [2]float64{4, 5}
+	var dst []plainPoint
+	err := Conv(src, &dst, op, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	debugOutput(dst)
+	if !cmp.Equal(src, dst) {
+		t.Error()
+	}
+	dst[0].X = 10
+	if src[0].X != 1 {
+		t.Error("slice is not copied")
+	}
+
+	dst1 := plainIgnoreEmpty{Label: "keep"}
+	err = Conv(plainIgnoreEmpty{X: 1}, &dst1, op, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	if !cmp.Equal(plainIgnoreEmpty{X: 1, Label: "keep"}, dst1) {
+		t.Error(dst1)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	defer SetCacheLimit(DefaultCacheLimit)
+	ResetCaches()
+	SetCacheLimit(2)
+
+	src := plainPoint{X: 1}
+	var dst plainPoint
+	for i := 0; i < 3; i++ {
+		if err := Conv(src, &dst, nil, *new(ParamList)); err != nil {
+			t.Error(err)
+		}
+	}
+	stats := ConverterCacheStats()
+	debugOutput(stats)
+	if stats.Misses != 1 || stats.Hits != 2 || stats.Entries != 1 || stats.Limit != 2 {
+		t.Error(stats)
+	}
+
+	var dst1 recursiveNode
+	if err := Conv(recursiveNode{Name: "a"}, &dst1, nil, *new(ParamList)); err != nil {
+		t.Error(err)
+	}
+	stats = ConverterCacheStats()
+	debugOutput(stats)
+	if stats.Entries != 2 || stats.Evictions == 0 {
+		t.Error(stats)
+	}
+	if StructFieldCacheStats().Entries > 2 {
+		t.Error(StructFieldCacheStats())
+	}
+
+	ResetCaches()
+	if ConverterCacheStats() != (CacheStats{Limit: 2}) || StructFieldCacheStats() != (CacheStats{Limit: 2}) {
+		t.Error(ConverterCacheStats(), StructFieldCacheStats())
+	}
+
+	// plans being built are not evicted, recursive types find them again
+	for _, limit := range []int{1, 2} {
+		SetCacheLimit(limit)
+		src := recursiveList{Name: "a", Next: &recursiveList{Name: "b"}}
+		var dst recursiveList
+		if err := Conv(src, &dst, new(Options).SetDeepCode(true), *new(ParamList)); err != nil {
+			t.Error(err)
+		}
+		if !cmp.Equal(src, dst) || dst.Next == src.Next {
+			t.Error(limit, dst)
+		}
+	}
+}
+
+func TestLruCache(t *testing.T) {
+	c := newLruCache(2)
+	c.Store("a", 1)
+	c.Store("b", 2)
+	c.Load("a")
+	c.Store("c", 3) // b is not used since stored
+	if _, ok := c.Load("b"); ok {
+		t.Error("b is not evicted")
+	}
+	if v, ok := c.Load("a"); !ok || v != 1 {
+		t.Error(v)
+	}
+	c.Store("c", 4)
+	if v, _ := c.Load("c"); v != 4 {
+		t.Error(v)
+	}
+	c.Delete("a")
+	c.setLimit(0)
+	for i := 0; i < 10; i++ {
+		c.Store(i, i)
+	}
+	if stats := c.stats(); stats.Entries != 11 || stats.Evictions != 1 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Error(stats)
+	}
+	c.setLimit(3)
+	if stats := c.stats(); stats.Entries != 3 || stats.Evictions != 9 {
+		t.Error(stats)
+	}
+}
+
+type recursiveList struct {
+	Name string
+	Next *recursiveList
+}
+
+func TestOptionsFreeze(t *testing.T) {
+	op := new(Options).SetDeepCode(true)
+	frozen := op.Freeze()
+	if !frozen.Frozen() || op.Frozen() || frozen.Freeze() != frozen || frozen == op {
+		t.Error()
+	}
+	if frozen.key() != op.key() {
+		t.Error("key of frozen options differs")
+	}
+	changed := frozen.SetDeepCode(false)
+	if changed == frozen || !frozen.DeepCopy || changed.DeepCopy || changed.Frozen() {
+		t.Error("frozen options are changed")
+	}
+
+	// the frozen copy is reused until op is changed by a setter or directly
+	if op.Freeze() != frozen {
+		t.Error("frozen copy is not reused")
+	}
+	if again := op.SetNilPolicy(NilZero).Freeze(); again == frozen || again.NilPolicy != NilZero {
+		t.Error("frozen copy is reused after a setter")
+	}
+	frozen = op.Freeze()
+	op.DeepCopy = false
+	if again := op.Freeze(); again == frozen || again.DeepCopy {
+		t.Error("frozen copy is reused after a field is written")
+	}
+	op.AddLocalRule(NewLocalRuleGroup("").AddRule("id", map[string]interface{}{"default": "a"}))
+	frozen = op.Freeze()
+	op.LocalRules[0] = NewLocalRuleGroup("").AddRule("id", map[string]interface{}{"default": "b"})
+	if again := op.Freeze(); again == frozen || again.key() == frozen.key() {
+		t.Error("frozen copy is reused after a rule group is replaced")
+	}
+
+	// fields written on a frozen Options are not hidden by its key
+	frozen = new(Options).Freeze()
+	var dst benchPostRepliesResp
+	if err := Conv(benchPost(), &dst, frozen, nil); err != nil {
+		t.Fatal(err)
+	}
+	frozen.DeepCopy = true
+	src := benchPost()
+	if err := Conv(src, &dst, frozen, nil); err != nil {
+		t.Fatal(err)
+	}
+	if &dst.Replies[0] == &src.Replies[0] {
+		t.Error("plan of the old settings is used")
+	}
+	if again := frozen.Freeze(); again == frozen || again.key() == frozen.key() || !again.DeepCopy {
+		t.Error("frozen Options written directly is not frozen again")
+	}
+
+	// rules of nested fields are seen through their path and never rewritten
+	grp := NewLocalRuleGroup("author").AddRule("avatar", map[string]interface{}{"default": "local.jpg"})
+	frozen = new(Options).AddLocalRule(grp).Freeze()
+	src = benchPost()
+	src.Author.Avatar = ""
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var dst benchPostResp
+			if err := Conv(&src, &dst, frozen, nil); err != nil {
+				t.Error(err)
+			}
+			if dst.Author.Avatar != "local.jpg" {
+				t.Error(dst.Author)
+			}
+		}()
+	}
+	wg.Wait()
+	if grp.Path != "author" || frozen.LocalRules[0].Path != "author" {
+		t.Error("path of rule is rewritten")
+	}
+}
+
+func keyTestFunc(user *User1, data dbUser, list ParamList) error {
+	return nil
+}
+
+func TestOptionsKey(t *testing.T) {
+	closure := func(avatar string) func(*User1, dbUser, ParamList) error {
+		return func(user *User1, data dbUser, list ParamList) error {
+			user.Avatar = avatar
+			return nil
+		}
+	}
+	rules := func(field string, operation map[string]interface{}) *Options {
+		return new(Options).AddLocalRule(NewLocalRuleGroup("").AddRule(field, operation))
+	}
+
+	equal := [][2]*Options{
+		{new(Options), new(Options).SetNilPolicy(NilDefault)},
+		{rules("avatar", map[string]interface{}{"default": "a.jpg", "ignoreEmpty": true}),
+			rules("avatar", map[string]interface{}{"ignoreEmpty": true, "default": "a.jpg"})},
+		{rules("avatar", map[string]interface{}{"func": keyTestFunc}),
+			rules("avatar", map[string]interface{}{"func": keyTestFunc})},
+		{rules("id", map[string]interface{}{"oneof": []string{"a", "b"}}),
+			rules("id", map[string]interface{}{"oneof": []string{"a", "b"}}).Freeze()},
+	}
+	for i, pair := range equal {
+		if pair[0].key() != pair[1].key() {
+			t.Error(i, pair[0].key(), pair[1].key())
+		}
+	}
+
+	fa, fb := closure("a.jpg"), closure("b.jpg")
+	unequal := [][2]*Options{
+		{new(Options), new(Options).SetDeepCode(true)},
+		{new(Options).SetNilPolicy(NilSkip), new(Options).SetNilPolicy(NilZero)},
+		{rules("avatar", map[string]interface{}{"default": "a.jpg"}),
+			rules("avatar", map[string]interface{}{"default": "b.jpg"})},
+		{rules("avatar", map[string]interface{}{"required": true}),
+			rules("avatar", map[string]interface{}{"required": "true"})},
+		{rules("avatar", map[string]interface{}{"default": "a.jpg"}),
+			rules("id", map[string]interface{}{"default": "a.jpg"})},
+		{rules("avatar", map[string]interface{}{"func": fa}),
+			rules("avatar", map[string]interface{}{"func": fb})},
+		{new(Options).AddLocalRule(NewLocalRuleGroup("").AddRule("id", map[string]interface{}{"ignoreEmpty": true})),
+			new(Options).AddLocalRule(NewLocalRuleGroup("author").AddRule("id", map[string]interface{}{"ignoreEmpty": true}))},
+		{new(Options).AddLocalRule(NewLocalRuleGroup("").AddRule("id", map[string]interface{}{"default": "a"})).
+			AddLocalRule(NewLocalRuleGroup("").AddRule("id", map[string]interface{}{"default": "b"})),
+			new(Options).AddLocalRule(NewLocalRuleGroup("").AddRule("id", map[string]interface{}{"default": "b"})).
+				AddLocalRule(NewLocalRuleGroup("").AddRule("id", map[string]interface{}{"default": "a"}))},
+	}
+	for i, pair := range unequal {
+		if pair[0].key() == pair[1].key() {
+			t.Error(i, pair[0].key())
+		}
+	}
+
+	// closures of the same code get their own plan
+	for avatar, fn := range map[string]interface{}{"a.jpg": fa, "b.jpg": fb} {
+		var dst User1
+		err := Conv(dbUser{ID: "1"}, &dst, rules("avatar", map[string]interface{}{"func": fn}), *new(ParamList))
+		if err != nil {
+			t.Error(err)
+		}
+		if dst.Avatar != avatar {
+			t.Error(dst)
+		}
+	}
+}
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) Trace(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) count(kind EventKind, field string) int {
+	n := 0
+	for _, e := range r.events {
+		if e.Kind == kind && e.Field == field {
+			n++
+		}
+	}
+	return n
+}
+
+func TestTracer(t *testing.T) {
+	ResetCaches()
+	r := new(eventRecorder)
+	op := new(Options).SetTracer(r)
+	var dst User1
+	err := Conv(dbUser{ID: "1", Avatar: "a.jpg"}, &dst, op, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	debugOutput(r.events)
+	if r.count(EventPlanBuilt, "") != 3 || r.count(EventCacheMiss, "") != 4 || r.count(EventCacheHit, "") != 0 ||
+		r.count(EventCustomFunc, "ID") != 1 || r.count(EventFieldConverted, "Avatar") != 1 {
+		t.Error(r.events)
+	}
+
+	r.events = nil
+	err = Conv(dbUser{ID: "1"}, &dst, op, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	if r.count(EventPlanBuilt, "") != 0 || r.events[0].Kind != EventCacheHit || r.events[0].Cache != CacheConverter ||
+		r.count(EventFieldConverted, "Avatar") != 0 { // avatar is ignored when empty
+		t.Error(r.events)
+	}
+
+	// the tracer of Options overrides the global one
+	global := new(eventRecorder)
+	SetTracer(global)
+	defer SetTracer(nil)
+	r.events = nil
+	err = Conv(dbUser{ID: "1"}, &dst, nil, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	err = Conv(dbUser{ID: "1"}, &dst, op, *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(global.events) == 0 || global.count(EventCustomFunc, "ID") != 1 || r.count(EventCustomFunc, "ID") != 1 {
+		t.Error(global.events, r.events)
+	}
+
+	// plans are shared by Options differing in Tracer, converters looked up
+	// while converting trace to the Tracer of the call
+	r1, r2 := new(eventRecorder), new(eventRecorder)
+	src := tracedSrc{Name: "a", Extra: map[string]interface{}{"any": "b"}}
+	list := ParamList{"viewer": "c"}
+	var dst1, dst2 tracedDst
+	if err := Conv(src, &dst1, new(Options).SetTracer(r1), list); err != nil {
+		t.Fatal(err)
+	}
+	r1.events = nil
+	if err := Conv(src, &dst2, new(Options).SetTracer(r2), list); err != nil {
+		t.Fatal(err)
+	}
+	debugOutput(r2.events)
+	if len(r1.events) != 0 || r2.count(EventCacheHit, "") != 3 || dst2 != (tracedDst{Name: "a", Any: "b", Viewer: "c"}) {
+		t.Error(r1.events, r2.events, dst2)
+	}
+}
+
+type tracedSrc struct {
+	Name  string
+	Extra map[string]interface{}
+}
+
+type tracedDst struct {
+	Name   string
+	Any    string `conv:",from=Extra.any"`
+	Viewer string `conv:"viewer,param"`
+}
+
+func TestExplain(t *testing.T) {
+	var dst []benchPostRepliesResp
+	plan, err := Explain([]dbPostWithReplies{}, &dst, new(Options).SetDeepCode(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		t.Error(err)
+	}
+	debugOutput(string(js))
+	if plan.Converter != ConverterSlice || plan.Elem == nil || plan.Elem.Converter != ConverterStruct {
+		t.Fatal(plan)
+	}
+	fields := make(map[string]*FieldPlan)
+	for _, fp := range plan.Elem.Fields {
+		fields[fp.Name] = fp
+	}
+	if fp := fields["benchPostResp"]; fp != nil {
+		t.Error("embedded struct is not flattened", fp)
+	}
+	if fp := fields["ID"]; fp == nil || fp.Source != SourceField || fp.Alias != "id" || fp.Plan.Converter != ConverterBasic {
+		t.Error(fp)
+	}
+	if fp := fields["Replies"]; fp == nil || fp.Plan.Converter != ConverterPlainSlice || fp.Plan.Kind != "slice" {
+		t.Error(fp)
+	}
+	if fp := fields["Author"]; fp == nil || fp.Plan.Converter != ConverterStruct || len(fp.Plan.Fields) != 3 {
+		t.Error(fp)
+	}
+
+	plan, err = Explain(recursiveNode{}, &recursiveNode{}, new(Options).SetDeepCode(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := plan.Fields[1].Plan
+	if children.Converter != ConverterSlice || !children.Elem.Recursive || children.Elem.Fields != nil {
+		t.Error(children)
+	}
+
+	plan, err = Explain(dbUser{}, &User1{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Fields[0].Source != SourceFunc || plan.Fields[0].Plan != nil || !plan.Fields[1].IgnoreEmpty {
+		t.Error(plan.Fields[0], plan.Fields[1])
+	}
+}
+
+type renderResp struct {
+	ID    string `conv:"id"`
+	Token string `conv:"token,param,session.Token"`
+	Name  string `conv:"name,from=author.id"`
+}
+
+type renderSrc struct {
+	ID     string `conv:"id"`
+	Author dbUser `conv:"author"`
+}
+
+func TestRender(t *testing.T) {
+	user1, err := Explain(dbUser{}, &User1{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := Explain(dbUser{}, &User{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Explain([]renderSrc{}, &[]renderResp{}, new(Options).SetDeepCode(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := RenderDOT(user1, user, resp)
+	debugOutput(dot)
+	for _, line := range []string{
+		"\tn0 [label=\"{ssconv.dbUser|<ID> ID|<Avatar> Avatar|<Gender> Gender|<Age> Age}\"];\n",
+		"\tn1 [label=\"{ssconv.User1|<ID> ID|<Avatar> Avatar|<Gender> Gender}\"];\n",
+		"\tn0 -> n1:ID [label=\"func ssconv.(*User1).Hello\"];\n",
+		"\tn0:Avatar -> n1:Avatar [label=\"ignoreEmpty\"];\n",
+		"\tn0:Gender -> n2:Gender;\n",
+		"\tparams -> n4:Token [label=\"param session.Token\"];\n",
+		"\tn3 -> n4:Name [label=\"from author.id\"];\n",
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("%q not found", line)
+		}
+	}
+	if strings.Count(dot, "ssconv.dbUser") != 1 {
+		t.Error("dbUser is drawn more than once")
+	}
+
+	mermaid := RenderMermaid(user1, user, resp)
+	debugOutput(mermaid)
+	for _, line := range []string{
+		"flowchart LR\n",
+		"\tsubgraph n0[\"ssconv.dbUser\"]\n\t\tn0_ID[\"ID\"]\n",
+		"\tn0 -->|\"func ssconv.(*User1).Hello\"| n1_ID\n",
+		"\tn0_Avatar -->|\"ignoreEmpty\"| n1_Avatar\n",
+		"\tparams -->|\"param session.Token\"| n4_Token\n",
+	} {
+		if !strings.Contains(mermaid, line) {
+			t.Errorf("%q not found", line)
+		}
+	}
+
+	// a type converted to itself has a src node and a dst node
+	self, err := Explain(recursiveNode{}, &recursiveNode{}, new(Options).SetDeepCode(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mermaid = RenderMermaid(self)
+	debugOutput(mermaid)
+	if !strings.Contains(mermaid, "\tn0_Name --> n1_Name\n") || strings.Count(mermaid, "subgraph") != 2 {
+		t.Error(mermaid)
+	}
+}
+
+type reportSrc struct {
+	ID      string        `conv:"id"`
+	Author  dbUser        `conv:"author"`
+	Replies []reportReply `conv:"replies"`
+}
+
+type reportReply struct {
+	ID      string `conv:"id"`
+	Content string `conv:"content,ignoreEmpty"`
+}
+
+type reportResp struct {
+	ID      string        `conv:"id"`
+	Author  User1         `conv:"author"`
+	Replies []reportReply `conv:"replies"`
+	Level   int           `conv:"level,default=1"`
+	Token   string        `conv:"token,param,session,ignoreEmpty"`
+	Name    string        `conv:"name,from=author.id"`
+}
+
+func TestConvReport(t *testing.T) {
+	src := reportSrc{
+		ID:      "post",
+		Author:  dbUser{ID: "yokel"},
+		Replies: []reportReply{{ID: "r0", Content: "hi"}, {ID: "r1"}},
+	}
+	var dst reportResp
+	report, err := ConvReport(src, &dst, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debugOutput(report.Fields)
+	for _, expect := range []FieldReport{
+		{Path: "ID", Source: SourceField, From: "ID", Outcome: OutcomeSet},
+		{Path: "Author", Source: SourceField, From: "Author", Outcome: OutcomeSet},
+		{Path: "Author.ID", Source: SourceFunc, From: "ssconv.(*User1).Hello", Outcome: OutcomeSet},
+		{Path: "Author.Avatar", Source: SourceField, From: "Author.Avatar", Outcome: OutcomeSkipped},
+		{Path: "Author.Gender", Source: SourceField, From: "Author.Gender", Outcome: OutcomeSet},
+		{Path: "Replies", Source: SourceField, From: "Replies", Outcome: OutcomeSet},
+		{Path: "Level", Source: SourceDefault, Outcome: OutcomeDefault},
+		{Path: "Token", Source: SourceParam, From: "session", Outcome: OutcomeSkipped},
+		{Path: "Name", Source: SourcePath, From: "author.id", Outcome: OutcomeSet},
+	} {
+		if got, ok := report.Field(expect.Path); !ok || got != expect {
+			t.Error(expect, got)
+		}
+	}
+	// elements are only visited when they are deep copied
+	if _, ok := report.Field("Replies[0].ID"); ok {
+		t.Error(report.Fields)
+	}
+
+	report, err = ConvReport(src, &dst, new(Options).SetDeepCode(true), ParamList{"session": "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []FieldReport{
+		{Path: "Replies[0].Content", Source: SourceField, From: "Replies[0].Content", Outcome: OutcomeSet},
+		{Path: "Replies[1].Content", Source: SourceField, From: "Replies[1].Content", Outcome: OutcomeSkipped},
+		{Path: "Token", Source: SourceParam, From: "session", Outcome: OutcomeSet},
+	} {
+		if got, ok := report.Field(expect.Path); !ok || got != expect {
+			t.Error(expect, got)
+		}
+	}
+
+	// plain structs copied as a whole are reported field by field
+	var point plainPoint
+	report, err = ConvReport(plainPoint{X: 1}, &point, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range report.Fields {
+		paths = append(paths, f.Path)
+	}
+	if !cmp.Equal([]string{"X", "Y", "Label", "Box", "Box.Size"}, paths) {
+		t.Error(report.Fields)
+	}
+	var points []plainPoint
+	report, err = ConvReport([]plainPoint{{}, {}}, &points, new(Options).SetDeepCode(true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := FieldReport{Path: "[1].Box.Size", Source: SourceField, From: "[1].Box.Size", Outcome: OutcomeSet}
+	if got, ok := report.Field(expect.Path); !ok || got != expect || len(report.Fields) != 10 {
+		t.Error(report.Fields)
+	}
+}
+
+type nilSrc struct {
+	Next  *recursiveNode
+	Tags  []string
+	Attrs map[string]int
+	Any   interface{}
+	Count *int
+}
+
+type nilDst struct {
+	Next  *recursiveNode
+	Tags  []string
+	Attrs map[string]int
+	Any   interface{}
+	Count int `conv:",nil=zero"`
+}
+
+func TestNilPolicy(t *testing.T) {
+	filled := func() nilDst {
+		return nilDst{Next: &recursiveNode{Name: "old"}, Tags: []string{"old"}, Attrs: map[string]int{"old": 1}, Any: 1, Count: 1}
+	}
+
+	// nil values are copied to dst by default, nil pointers to values fail
+	for _, deepCopy := range []bool{false, true} {
+		dst := filled()
+		err := Conv(nilSrc{}, &dst, new(Options).SetDeepCode(deepCopy), *new(ParamList))
+		if err != nil {
+			t.Error(err)
+		}
+		if !cmp.Equal(nilDst{}, dst) {
+			t.Error(deepCopy, dst)
+		}
+	}
+	var x *int
+	var y int
+	err := Conv(&x, &y, nil, *new(ParamList))
+	if err == nil || err.Error() != "ssconvError: value of *int in src is nil" {
+		t.Error(err)
+	}
+
+	for _, deepCopy := range []bool{false, true} {
+		dst := filled()
+		err = Conv(nilSrc{}, &dst, new(Options).SetDeepCode(deepCopy).SetNilPolicy(NilError), *new(ParamList))
+		if err == nil || err.Error() != "ssconvError: (ssconv.nilDst)Next: value of *ssconv.recursiveNode in src is nil" {
+			t.Error(err)
+		}
+
+		dst = filled()
+		err = Conv(nilSrc{}, &dst, new(Options).SetDeepCode(deepCopy).SetNilPolicy(NilSkip), *new(ParamList))
+		if err != nil {
+			t.Error(err)
+		}
+		expect := filled()
+		expect.Count = 0 // policy of field overrides that of options
+		if !cmp.Equal(expect, dst) {
+			t.Error(deepCopy, dst)
+		}
+
+		dst = filled()
+		err = Conv(nilSrc{}, &dst, new(Options).SetDeepCode(deepCopy).SetNilPolicy(NilZero), *new(ParamList))
+		if err != nil {
+			t.Error(err)
+		}
+		if !cmp.Equal(nilDst{}, dst) {
+			t.Error(deepCopy, dst)
+		}
+	}
+
+	// elements are nil sources as well
+	src := []*recursiveNode{{Name: "a"}, nil}
+	dst := []*recursiveNode{}
+	err = Conv(src, &dst, new(Options).SetDeepCode(true), *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(dst) != 2 || dst[0] == src[0] || dst[0].Name != "a" || dst[1] != nil {
+		t.Error(dst)
+	}
+	err = Conv([]*recursiveNode{nil}, &dst, new(Options).SetDeepCode(true).SetNilPolicy(NilError), *new(ParamList))
+	if err == nil || err.Error() != "ssconvError: value of *ssconv.recursiveNode in src is nil" {
+		t.Error(err)
+	}
+}
+
+type emptyNilResp struct {
+	Tags  []string       `json:"tags"`
+	Attrs map[string]int `json:"attrs"`
+	Empty []string       `json:"empty"`
+	Any   interface{}    `json:"any"`
+}
+
+func TestEmptyNil(t *testing.T) {
+	src := emptyNilResp{Empty: []string{}}
+	for _, deepCopy := range []bool{false, true} {
+		var dst emptyNilResp
+		err := Conv(src, &dst, new(Options).SetDeepCode(deepCopy), *new(ParamList))
+		if err != nil {
+			t.Error(err)
+		}
+		js, _ := json.Marshal(dst)
+		if string(js) != `{"tags":null,"attrs":null,"empty":[],"any":null}` {
+			t.Error(deepCopy, string(js))
+		}
+
+		dst = emptyNilResp{}
+		err = Conv(src, &dst, new(Options).SetDeepCode(deepCopy).SetEmptyNil(true).SetNilPolicy(NilError), *new(ParamList))
+		if err == nil || err.Error() != "ssconvError: (ssconv.emptyNilResp)Any: value of interface {} in src is nil" {
+			t.Error(err)
+		}
+
+		err = Conv(src, &dst, new(Options).SetDeepCode(deepCopy).SetEmptyNil(true), *new(ParamList))
+		if err != nil {
+			t.Error(err)
+		}
+		js, _ = json.Marshal(dst)
+		if string(js) != `{"tags":[],"attrs":{},"empty":[],"any":null}` {
+			t.Error(deepCopy, string(js))
+		}
+	}
+}
+
+type wrapAddress struct {
+	City string `conv:"city"`
+}
+
+type wrapSrc struct {
+	ID      *int           `conv:"id"`
+	Scores  []int          `conv:"scores"`
+	Address *wrapAddress   `conv:"address"`
+	Home    wrapAddress    `conv:"home"`
+	Level   **int32        `conv:"level"`
+	Extra   *[]wrapAddress `conv:"extra"`
+}
+
+type wrapDst struct {
+	ID      int64          `conv:"id"`
+	Scores  []*int64       `conv:"scores"`
+	Address wrapAddress    `conv:"address"`
+	Home    **wrapAddress  `conv:"home"`
+	Level   *uint8         `conv:"level"`
+	Extra   []*wrapAddress `conv:"extra"`
+}
+
+type (
+	wrapName    string
+	wrapFlag    bool
+	wrapComplex complex128
+)
+
+func TestPointerDepth(t *testing.T) {
+	id := 7
+	level := int32(3)
+	pLevel := &level
+	extra := []wrapAddress{{City: "c"}}
+	src := wrapSrc{
+		ID:      &id,
+		Scores:  []int{1, 2},
+		Address: &wrapAddress{City: "a"},
+		Home:    wrapAddress{City: "b"},
+		Level:   &pLevel,
+		Extra:   &extra,
+	}
+	for _, deepCopy := range []bool{false, true} {
+		var dst wrapDst
+		err := Conv(src, &dst, new(Options).SetDeepCode(deepCopy), *new(ParamList))
+		if err != nil {
+			t.Fatal(err)
+		}
+		debugOutput(dst)
+		one, two := int64(1), int64(2)
+		home := &wrapAddress{City: "b"}
+		three := uint8(3)
+		expect := wrapDst{
+			ID:      7,
+			Scores:  []*int64{&one, &two},
+			Address: wrapAddress{City: "a"},
+			Home:    &home,
+			Level:   &three,
+			Extra:   []*wrapAddress{{City: "c"}},
+		}
+		if !cmp.Equal(expect, dst) {
+			t.Error(deepCopy, cmp.Diff(expect, dst))
+		}
+		if dst.Extra[0] == &extra[0] {
+			t.Error("wrapped value is shared with src")
+		}
+	}
+
+	// nil policy applies on dereference
+	var dst wrapDst
+	err := Conv(wrapSrc{Scores: []int{}, Level: new(*int32)}, &dst, nil, *new(ParamList))
+	if err == nil || err.Error() != "ssconvError: (ssconv.wrapDst)ID: value of *int in src is nil" {
+		t.Error(err)
+	}
+	dst = wrapDst{ID: 1}
+	err = Conv(wrapSrc{Level: new(*int32)}, &dst, new(Options).SetNilPolicy(NilZero), *new(ParamList))
+	if err != nil {
+		t.Error(err)
+	}
+	home := &wrapAddress{}
+	if !cmp.Equal(wrapDst{Home: &home}, dst) {
+		t.Error(dst)
+	}
+
+	// numbers are converted only if they fit
+	for _, c := range []struct {
+		src interface{}
+		dst interface{}
+		err string
+	}{
+		{int64(300), new(int8), "ssconvError: value 300 of int64 in src does not fit int8 in dst"},
+		{-1, new(uint), "ssconvError: value -1 of int in src does not fit uint in dst"},
+		{1.5, new(int), "ssconvError: value 1.5 of float64 in src does not fit int in dst"},
+		{uint8(255), new(int8), "ssconvError: value 255 of uint8 in src does not fit int8 in dst"},
+		{2.0, new(uint16), ""},
+		{int8(-3), new(float32), ""},
+		{int64(1<<53 + 1), new(float64), "ssconvError: value 9007199254740993 of int64 in src does not fit float64 in dst"},
+		{int64(1 << 53), new(float64), ""},
+		{int32(1<<24 + 1), new(float32), "ssconvError: value 16777217 of int32 in src does not fit float32 in dst"},
+		{uint64(math.MaxUint64), new(float64), "ssconvError: value 18446744073709551615 of uint64 in src does not fit float64 in dst"},
+		{0.1, new(float32), "ssconvError: value 0.1 of float64 in src does not fit float32 in dst"},
+		{1e300, new(float32), "ssconvError: value 1e+300 of float64 in src does not fit float32 in dst"},
+		{0.5, new(float32), ""},
+		{float32(0.1), new(float64), ""},
+		{"s", new(int), "ssconvError: cant not assign string in src to int in dst"},
+		// basic types of the same kind are converted as well
+		{"s", new(wrapName), ""},
+		{true, new(wrapFlag), ""},
+		{complex(1, 2), new(wrapComplex), ""},
+		{complex128(1), new(complex64), "ssconvError: cant not assign complex128 in src to complex64 in dst"},
+	} {
+		err := Conv(c.src, c.dst, nil, *new(ParamList))
+		if (err == nil && c.err != "") || (err != nil && err.Error() != c.err) {
+			t.Error(c.src, err)
+		}
+	}
+
+	var named struct {
+		Name    wrapName
+		Flag    wrapFlag
+		Complex wrapComplex
+	}
+	err = Conv(struct {
+		Name    string
+		Flag    bool
+		Complex complex128
+	}{"n", true, complex(1, 2)}, &named, nil, *new(ParamList))
+	if err != nil || named.Name != "n" || !bool(named.Flag) || named.Complex != wrapComplex(complex(1, 2)) {
+		t.Error(err, named)
+	}
+
+	plan, err := Explain(wrapSrc{}, &wrapDst{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := plan.Fields[0].Plan; id.Converter != ConverterUnwrap || id.Elem.Converter != ConverterBasic {
+		t.Error(id)
+	}
+	if scores := plan.Fields[1].Plan; scores.Converter != ConverterSlice || scores.Elem.Converter != ConverterWrap {
+		t.Error(scores)
+	}
+}
+
+type refSrc struct {
+	Name   string
+	Hook   func() string
+	Done   chan int
+	Ptr    unsafe.Pointer
+	OnSave func() string
+}
+
+type refDst struct {
+	Name   string
+	Hook   func() string
+	Done   chan int
+	Ptr    unsafe.Pointer
+	OnSave func() string `conv:",ref=skip"`
+}
+
+func TestRefPolicy(t *testing.T) {
+	n := 1
+	done := make(chan int)
+	src := refSrc{Name: "a", Hook: func() string { return "hook" }, Done: done, Ptr: unsafe.Pointer(&n), OnSave: func() string { return "save" }}
+
+	// references are copied by default, deepcopy or not
+	for _, deepCopy := range []bool{false, true} {
+		var dst refDst
+		err := Conv(&src, &dst, new(Options).SetDeepCode(deepCopy), *new(ParamList))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dst.Name != "a" || dst.Hook() != "hook" || dst.Done != done || dst.Ptr != unsafe.Pointer(&n) || dst.OnSave != nil {
+			t.Error(deepCopy, dst)
+		}
+	}
+
+	// RefSkip leaves dst untouched
+	old := make(chan int)
+	dst := refDst{Done: old}
+	report, err := ConvReport(&src, &dst, new(Options).SetRefPolicy(RefSkip), *new(ParamList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.Name != "a" || dst.Hook != nil || dst.Done != old || dst.Ptr != nil {
+		t.Error(dst)
+	}
+	if f, _ := report.Field("Done"); f.Outcome != OutcomeSkipped {
+		t.Error(f)
+	}
+
+	// RefError fails when the plan is built, the field can still allow it
+	err = Conv(&src, &refDst{}, new(Options).SetRefPolicy(RefError), *new(ParamList))
+	if err == nil || err.Error() != "ssconvError: (ssconv.refDst)Hook: reference of func() string is not allowed" {
+		t.Error(err)
+	}
+	options := new(Options).SetRefPolicy(RefError).AddLocalRule(
+		NewLocalRuleGroup("").
+			AddRule("Hook", map[string]interface{}{"ref": "copy"}).
+			AddRule("Done", map[string]interface{}{"ref": RefSkip}).
+			AddRule("Ptr", map[string]interface{}{"ref": "skip"}))
+	dst = refDst{}
+	err = Conv(&src, &dst, options, *new(ParamList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.Hook() != "hook" || dst.Done != nil || dst.Ptr != nil {
+		t.Error(dst)
+	}
+
+	// func types must be assignable
+	var f func() int
+	err = Conv(src.Hook, &f, nil, *new(ParamList))
+	if err == nil || err.Error() != "ssconvError: cant not assign func() string in src to func() int in dst" {
+		t.Error(err)
+	}
+
+	plan, err := Explain(refSrc{}, &refDst{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Fields[1].Plan.Converter != ConverterReference || plan.Fields[4].Plan.Converter != ConverterSkip {
+		t.Error(plan.Fields[1].Plan, plan.Fields[4].Plan)
+	}
+}
@@ -0,0 +1,92 @@
+package ssconv
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// EventKind is the kind of a diagnostic Event
+type EventKind int
+
+const (
+	EventPlanBuilt      EventKind = iota // a converter is built for SrcType and DstType
+	EventCacheHit                        // a plan is found in Cache
+	EventCacheMiss                       // a plan is not found in Cache
+	EventFieldConverted                  // Field of DstType is written
+	EventCustomFunc                      // the custom func of Field of DstType is called
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventPlanBuilt:
+		return "planBuilt"
+	case EventCacheHit:
+		return "cacheHit"
+	case EventCacheMiss:
+		return "cacheMiss"
+	case EventFieldConverted:
+		return "fieldConverted"
+	case EventCustomFunc:
+		return "customFunc"
+	}
+	return "unknown"
+}
+
+// names of the caches reported in Event.Cache
+const (
+	CacheConverter   = "converter"
+	CacheStructField = "structField"
+)
+
+// Event is emitted to a Tracer while conversions are planned and run.
+// Fields not related to Kind are left empty
+type Event struct {
+	Kind    EventKind
+	SrcType reflect.Type // nil for struct field cache events
+	DstType reflect.Type // the parsed struct for struct field cache events
+	Field   string       // dst field name of field events
+	Cache   string       // cache name of cache events
+}
+
+// Tracer receives diagnostic events, it must be safe for concurrent use
+type Tracer interface {
+	Trace(e Event)
+}
+
+// TracerFunc adapts a func to Tracer
+type TracerFunc func(e Event)
+
+func (f TracerFunc) Trace(e Event) {
+	f(e)
+}
+
+// tracerBox lets atomic.Value hold a nil Tracer
+type tracerBox struct {
+	Tracer
+}
+
+var globalTracer atomic.Value
+
+func init() {
+	globalTracer.Store(tracerBox{})
+}
+
+// SetTracer sets the Tracer used by conversions whose Options have none,
+// nil makes them silent, which is the default
+func SetTracer(t Tracer) {
+	globalTracer.Store(tracerBox{t})
+}
+
+// tracer returns the Tracer of op, or the global one
+func (op *Options) tracer() Tracer {
+	if op != nil && op.Tracer != nil {
+		return op.Tracer
+	}
+	return globalTracer.Load().(tracerBox).Tracer
+}
+
+func trace(t Tracer, e Event) {
+	if t != nil {
+		t.Trace(e)
+	}
+}
@@ -0,0 +1,15 @@
+package ssconv
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// exposeField returns a readable and settable view of an unexported field,
+// v must be addressable
+func exposeField(v reflect.Value) reflect.Value {
+	if v.CanSet() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
@@ -0,0 +1,200 @@
+package ssconv
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validation holds the rules checked on a dst field right after it is converted
+type validation struct {
+	required bool
+	min      *float64
+	max      *float64
+	length   *int
+	oneof    []string
+	pattern  *regexp.Regexp
+}
+
+func (vd *validation) clone() *validation {
+	newvd := new(validation)
+	if vd != nil {
+		*newvd = *vd
+	}
+	return newvd
+}
+
+// isValidationRule reports whether key names a validation option of tags and LocalRules
+func isValidationRule(key string) bool {
+	switch key {
+	case "required", "min", "max", "len", "oneof", "regexp":
+		return true
+	}
+	return false
+}
+
+// setRule sets rule key of vd. value is the string of a tag option or the
+// operation value of a LocalRule
+func (vd *validation) setRule(key string, value interface{}) {
+	switch key {
+	case "required":
+		required, ok := value.(bool)
+		if s, isStr := value.(string); isStr {
+			required, ok = true, true
+			if s != "" {
+				var err error
+				required, err = strconv.ParseBool(s)
+				ok = err == nil
+			}
+		}
+		if !ok {
+			convPanicStr("required: value is not bool")
+		}
+		vd.required = required
+	case "min", "max":
+		n, ok := toFloat(value)
+		if !ok {
+			convPanicStr(key + ": value is not a number")
+		}
+		if key == "min" {
+			vd.min = &n
+		} else {
+			vd.max = &n
+		}
+	case "len":
+		n, ok := toFloat(value)
+		if !ok || n < 0 || n != float64(int(n)) {
+			convPanicStr("len: value is not a non-negative integer")
+		}
+		l := int(n)
+		vd.length = &l
+	case "oneof":
+		switch v := value.(type) {
+		case string:
+			vd.oneof = strings.Fields(v)
+		case []string:
+			vd.oneof = append([]string{}, v...)
+		default:
+			convPanicStr("oneof: value is neither string nor []string")
+		}
+	case "regexp":
+		pattern, ok := value.(string)
+		if !ok {
+			convPanicStr("regexp: value is not string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			convPanic(err)
+		}
+		vd.pattern = re
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	if s, ok := value.(string); ok {
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// size returns the number compared by min and max: the value of numbers and
+// the length of strings, slices, arrays and maps
+func size(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	l, ok := length(v)
+	return float64(l), ok
+}
+
+func length(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// validate panics ErrValidation if v breaks any rule of vd.
+// A nil pointer only breaks required, other rules are checked on the pointed value
+func (vd *validation) validate(v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if vd.required {
+				convPanic(&ErrValidation{rule: "required", reason: "value is nil"})
+			}
+			return
+		}
+		v = v.Elem()
+	}
+
+	if vd.required && v.IsZero() {
+		convPanic(&ErrValidation{rule: "required", reason: "value is empty"})
+	}
+
+	if vd.min != nil || vd.max != nil {
+		n, ok := size(v)
+		if !ok {
+			convPanic(&ErrValidation{rule: "min/max", reason: fmt.Sprintf("unsupported type %s", v.Type())})
+		}
+		if vd.min != nil && n < *vd.min {
+			convPanic(&ErrValidation{rule: fmt.Sprintf("min=%v", *vd.min), reason: fmt.Sprintf("got %v", n)})
+		}
+		if vd.max != nil && n > *vd.max {
+			convPanic(&ErrValidation{rule: fmt.Sprintf("max=%v", *vd.max), reason: fmt.Sprintf("got %v", n)})
+		}
+	}
+
+	if vd.length != nil {
+		l, ok := length(v)
+		if !ok {
+			convPanic(&ErrValidation{rule: "len", reason: fmt.Sprintf("unsupported type %s", v.Type())})
+		}
+		if l != *vd.length {
+			convPanic(&ErrValidation{rule: fmt.Sprintf("len=%d", *vd.length), reason: fmt.Sprintf("got %d", l)})
+		}
+	}
+
+	if len(vd.oneof) > 0 {
+		s := fmt.Sprint(v.Interface())
+		found := false
+		for _, o := range vd.oneof {
+			if o == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			convPanic(&ErrValidation{rule: "oneof=" + strings.Join(vd.oneof, " "), reason: fmt.Sprintf("got %q", s)})
+		}
+	}
+
+	if vd.pattern != nil {
+		if v.Kind() != reflect.String {
+			convPanic(&ErrValidation{rule: "regexp", reason: fmt.Sprintf("unsupported type %s", v.Type())})
+		}
+		if !vd.pattern.MatchString(v.String()) {
+			convPanic(&ErrValidation{rule: "regexp=" + vd.pattern.String(), reason: fmt.Sprintf("got %q", v.String())})
+		}
+	}
+}